@@ -0,0 +1,263 @@
+package streamgo
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat selects how access log records are written.
+type AccessLogFormat int
+
+const (
+	// AccessLogJSON writes one JSON object per request.
+	AccessLogJSON AccessLogFormat = iota
+
+	// AccessLogLogfmt writes key=value pairs per request.
+	AccessLogLogfmt
+
+	// AccessLogCombined writes the Apache combined log format.
+	// The fields are fixed by the format; AccessLogOptions.Fields is ignored.
+	AccessLogCombined
+)
+
+// AccessLogField names an attribute of an access log record.
+type AccessLogField string
+
+const (
+	AccessLogMethod    AccessLogField = "method"
+	AccessLogRoute     AccessLogField = "route"
+	AccessLogURI       AccessLogField = "uri"
+	AccessLogProto     AccessLogField = "proto"
+	AccessLogParams    AccessLogField = "params"
+	AccessLogStatus    AccessLogField = "status"
+	AccessLogBytes     AccessLogField = "bytes"
+	AccessLogDuration  AccessLogField = "duration"
+	AccessLogIP        AccessLogField = "ip"
+	AccessLogUserAgent AccessLogField = "user_agent"
+	AccessLogReferer   AccessLogField = "referer"
+	AccessLogDevice    AccessLogField = "device"
+	AccessLogRequestID AccessLogField = "request_id"
+)
+
+// DefaultAccessLogFields are the fields logged when AccessLogOptions.Fields is empty.
+var DefaultAccessLogFields = []AccessLogField{
+	AccessLogMethod,
+	AccessLogRoute,
+	AccessLogParams,
+	AccessLogStatus,
+	AccessLogBytes,
+	AccessLogDuration,
+	AccessLogIP,
+	AccessLogUserAgent,
+	AccessLogDevice,
+	AccessLogRequestID,
+}
+
+// combinedFields are the fields the Apache combined format is built from.
+var combinedFields = []AccessLogField{
+	AccessLogIP,
+	AccessLogMethod,
+	AccessLogURI,
+	AccessLogProto,
+	AccessLogStatus,
+	AccessLogBytes,
+	AccessLogReferer,
+	AccessLogUserAgent,
+}
+
+// AccessLogOptions configures the access log of a Server.
+// One record is emitted per request, after the handler returned.
+// The ip field is HTTPRequest.ClientIP, the client IP determined by Server.ClientIPResolver.
+//
+// The logger and fields are resolved on the first request, so an AccessLogOptions must not
+// be copied or changed after it was used; go vet reports such copies. Set a new one instead.
+type AccessLogOptions struct {
+	// Logger receives the records. When nil, a logger writing Format to Output is created.
+	Logger *slog.Logger
+
+	// Output is where records are written when Logger is nil. It defaults to os.Stdout.
+	Output io.Writer
+
+	// Format selects the output format when Logger is nil.
+	Format AccessLogFormat
+
+	// Level is the level of every record.
+	Level slog.Level
+
+	// Fields lists the attributes of each record, in order. It defaults to DefaultAccessLogFields.
+	Fields []AccessLogField
+
+	// SampleRate is the fraction of requests that are logged, between 0 and 1.
+	// Zero logs every request. Responses with a 5xx status are always logged.
+	SampleRate float64
+
+	once   sync.Once
+	logger *slog.Logger
+	fields []AccessLogField
+}
+
+// init resolves the logger and fields once.
+func (o *AccessLogOptions) init() {
+	o.once.Do(func() {
+		o.fields = o.Fields
+		if len(o.fields) == 0 {
+			o.fields = DefaultAccessLogFields
+		}
+
+		o.logger = o.Logger
+		if o.logger != nil {
+			return
+		}
+
+		out := o.Output
+		if out == nil {
+			out = os.Stdout
+		}
+		handlerOpts := &slog.HandlerOptions{Level: o.Level}
+		switch o.Format {
+		case AccessLogLogfmt:
+			o.logger = slog.New(slog.NewTextHandler(out, handlerOpts))
+		case AccessLogCombined:
+			o.fields = combinedFields
+			o.logger = slog.New(&combinedHandler{out: out, level: o.Level, mu: &sync.Mutex{}})
+		default:
+			o.logger = slog.New(slog.NewJSONHandler(out, handlerOpts))
+		}
+	})
+}
+
+// sampled reports whether a response with status should be logged.
+func (o *AccessLogOptions) sampled(status int) bool {
+	if o.SampleRate <= 0 || o.SampleRate >= 1 || status >= 500 {
+		return true
+	}
+	return rand.Float64() < o.SampleRate
+}
+
+// log emits the record of a finished request. route is the pattern of the matched Path,
+// empty when no route matched.
+func (o *AccessLogOptions) log(request *HTTPRequest, response *HTTPResponse, route string) {
+	o.init()
+
+	status := response.StatusCode()
+	if status == 0 {
+		// net/http replies 200 when the handler wrote nothing.
+		status = 200
+	}
+	if !o.sampled(status) {
+		return
+	}
+
+	rw := response.recorder()
+	r := request.HTTP
+	attrs := make([]slog.Attr, 0, len(o.fields))
+	for _, field := range o.fields {
+		key := string(field)
+		switch field {
+		case AccessLogMethod:
+			attrs = append(attrs, slog.String(key, r.Method))
+		case AccessLogRoute:
+			attrs = append(attrs, slog.String(key, route))
+		case AccessLogURI:
+			attrs = append(attrs, slog.String(key, r.RequestURI))
+		case AccessLogProto:
+			attrs = append(attrs, slog.String(key, r.Proto))
+		case AccessLogParams:
+			attrs = append(attrs, slog.Any(key, request.Params))
+		case AccessLogStatus:
+			attrs = append(attrs, slog.Int(key, status))
+		case AccessLogBytes:
+			attrs = append(attrs, slog.Int64(key, rw.written))
+		case AccessLogDuration:
+			attrs = append(attrs, slog.Duration(key, time.Since(rw.start)))
+		case AccessLogIP:
+			attrs = append(attrs, slog.String(key, request.ClientIP()))
+		case AccessLogUserAgent:
+			attrs = append(attrs, slog.String(key, r.UserAgent()))
+		case AccessLogReferer:
+			attrs = append(attrs, slog.String(key, r.Referer()))
+		case AccessLogDevice:
+			browser, platform := request.Device()
+			attrs = append(attrs, slog.Group(key, slog.String("browser", browser), slog.String("os", platform)))
+		case AccessLogRequestID:
+			attrs = append(attrs, slog.String(key, request.RequestID()))
+		}
+	}
+
+	o.logger.LogAttrs(r.Context(), o.Level, "request", attrs...)
+}
+
+// combinedHandler writes records in the Apache combined log format:
+//
+//	host - - [time] "method uri proto" status bytes "referer" "user agent"
+type combinedHandler struct {
+	out   io.Writer
+	level slog.Level
+	mu    *sync.Mutex
+	attrs []slog.Attr
+}
+
+func (h *combinedHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *combinedHandler) Handle(_ context.Context, record slog.Record) error {
+	values := map[string]string{}
+	collect := func(a slog.Attr) bool {
+		values[a.Key] = a.Value.String()
+		return true
+	}
+	for _, a := range h.attrs {
+		collect(a)
+	}
+	record.Attrs(collect)
+
+	field := func(key string) string {
+		if v := values[key]; v != "" {
+			return v
+		}
+		return "-"
+	}
+	bytes := values[string(AccessLogBytes)]
+	if bytes == "" || bytes == "0" {
+		bytes = "-"
+	}
+
+	var line strings.Builder
+	line.WriteString(field(string(AccessLogIP)))
+	line.WriteString(" - - [")
+	line.WriteString(record.Time.Format("02/Jan/2006:15:04:05 -0700"))
+	line.WriteString("] ")
+	line.WriteString(strconv.Quote(values[string(AccessLogMethod)] + " " + values[string(AccessLogURI)] + " " + values[string(AccessLogProto)]))
+	line.WriteByte(' ')
+	line.WriteString(field(string(AccessLogStatus)))
+	line.WriteByte(' ')
+	line.WriteString(bytes)
+	line.WriteByte(' ')
+	line.WriteString(strconv.Quote(field(string(AccessLogReferer))))
+	line.WriteByte(' ')
+	line.WriteString(strconv.Quote(field(string(AccessLogUserAgent))))
+	line.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, line.String())
+	return err
+}
+
+func (h *combinedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &clone
+}
+
+func (h *combinedHandler) WithGroup(string) slog.Handler {
+	return h
+}
@@ -0,0 +1,95 @@
+package streamgo
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func newAccessLogServer(t *testing.T, opts *AccessLogOptions) *Server[string] {
+	t.Helper()
+
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		response.Status(201)
+		response.HTML("created")
+	}, []Path[string]{{Name: "/users/:id:", HTTP: HTTP{Methods: map[HTTPMethod]bool{GET: true, POST: true}}}})
+	s.AccessLog = opts
+	s.ClientIPResolver = resolver
+	return s
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	var out bytes.Buffer
+	s := newAccessLogServer(t, &AccessLogOptions{Output: &out})
+
+	r := httptest.NewRequest("POST", "/users/5", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("Forwarded", "for=203.0.113.5")
+	r.Header.Set("X-Request-ID", "abc")
+	r.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0")
+	do(s, r)
+
+	var record struct {
+		Method    string            `json:"method"`
+		Route     string            `json:"route"`
+		Params    map[string]string `json:"params"`
+		Status    int               `json:"status"`
+		Bytes     int               `json:"bytes"`
+		IP        string            `json:"ip"`
+		RequestID string            `json:"request_id"`
+		Device    map[string]string `json:"device"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+
+	if record.Method != "POST" || record.Route != "/users/:id:/" || record.Params["id"] != "5" {
+		t.Errorf("route fields = %+v", record)
+	}
+	if record.Status != 201 || record.Bytes != len("created") {
+		t.Errorf("status %d, bytes %d, want 201, %d", record.Status, record.Bytes, len("created"))
+	}
+	// The client IP goes through the server's resolver, which understands Forwarded.
+	if record.IP != "203.0.113.5" {
+		t.Errorf("ip = %q, want 203.0.113.5", record.IP)
+	}
+	if record.RequestID != "abc" {
+		t.Errorf("request_id = %q, want abc", record.RequestID)
+	}
+	if record.Device["browser"] != "Mozilla Firefox" || record.Device["os"] != "Linux" {
+		t.Errorf("device = %v", record.Device)
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	var out bytes.Buffer
+	s := newAccessLogServer(t, &AccessLogOptions{Output: &out, Format: AccessLogCombined})
+
+	r := httptest.NewRequest("GET", "/users/5?x=1", nil)
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("User-Agent", "curl/8")
+	do(s, r)
+
+	want := regexp.MustCompile(`^192\.0\.2\.1 - - \[[^]]+\] "GET /users/5\?x=1 HTTP/1\.1" 201 7 "https://example\.com/" "curl/8"\n$`)
+	if !want.Match(out.Bytes()) {
+		t.Errorf("combined line = %q", out.String())
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	var out bytes.Buffer
+	s := newAccessLogServer(t, &AccessLogOptions{Output: &out, SampleRate: 0.000001})
+
+	for i := 0; i < 20; i++ {
+		serve(s, "GET", "/users/5")
+	}
+	if out.Len() != 0 {
+		t.Errorf("sampled out requests were logged: %s", out.String())
+	}
+}
@@ -0,0 +1,179 @@
+package streamgo
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPSource is a place the client IP can be taken from.
+type ClientIPSource byte
+
+const (
+	// ClientIPForwarded reads the for= parameters of the RFC 7239 Forwarded header.
+	ClientIPForwarded ClientIPSource = iota
+	// ClientIPXForwardedFor reads the X-Forwarded-For header.
+	ClientIPXForwardedFor
+	// ClientIPXRealIP reads the X-Real-IP header.
+	ClientIPXRealIP
+	// ClientIPCFConnectingIP reads the CF-Connecting-IP header set by Cloudflare.
+	ClientIPCFConnectingIP
+	// ClientIPProxyProtocol uses the address conveyed by a PROXY protocol header.
+	ClientIPProxyProtocol
+)
+
+// DefaultClientIPSources is the order used when NewClientIPResolver gets no sources.
+var DefaultClientIPSources = []ClientIPSource{
+	ClientIPForwarded,
+	ClientIPXForwardedFor,
+	ClientIPXRealIP,
+	ClientIPCFConnectingIP,
+	ClientIPProxyProtocol,
+}
+
+// ClientIPResolver determines the real client IP of a request.
+// Headers are only believed when the peer that sent the request is a trusted proxy.
+// In address chains, the right-most address that is not a trusted proxy is the client.
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+	sources []ClientIPSource
+}
+
+// NewClientIPResolver parses the trusted proxy IPs and CIDRs once and
+// consults sources in the given order, falling back to the peer address.
+func NewClientIPResolver(trustedProxies []string, sources ...ClientIPSource) (*ClientIPResolver, error) {
+	trusted, err := parseTrustedNetworks(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		sources = DefaultClientIPSources
+	}
+	return &ClientIPResolver{trusted: trusted, sources: sources}, nil
+}
+
+// Resolve returns the client IP of r in its canonical text form.
+func (c *ClientIPResolver) Resolve(r *http.Request) string {
+	peer := hostOf(r.RemoteAddr)
+	peerIP := net.ParseIP(peer)
+	trustedPeer := peerIP != nil && containsIP(c.trusted, peerIP)
+
+	for _, source := range c.sources {
+		var ip net.IP
+		switch source {
+		case ClientIPProxyProtocol:
+			if addr := proxiedAddr(r.Context()); addr != nil {
+				return hostOf(addr.String())
+			}
+			continue
+		case ClientIPForwarded:
+			if trustedPeer {
+				ip = c.fromChain(forwardedFor(r.Header.Values("Forwarded")))
+			}
+		case ClientIPXForwardedFor:
+			if trustedPeer {
+				ip = c.fromChain(splitList(r.Header.Values("X-Forwarded-For")))
+			}
+		case ClientIPXRealIP:
+			if trustedPeer {
+				ip = net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+			}
+		case ClientIPCFConnectingIP:
+			if trustedPeer {
+				ip = net.ParseIP(strings.TrimSpace(r.Header.Get("CF-Connecting-IP")))
+			}
+		}
+
+		if ip != nil {
+			return ip.String()
+		}
+	}
+
+	if peerIP != nil {
+		return peerIP.String()
+	}
+	return peer
+}
+
+// fromChain walks a proxy chain from the right and returns the first address
+// that is not a trusted proxy, or the left-most address when all of them are.
+// It returns nil when it reaches a hop that is not an IP, such as "unknown" or an
+// obfuscated identifier, so that the next source or the peer address is used instead.
+func (c *ClientIPResolver) fromChain(chain []string) net.IP {
+	var leftmost net.IP
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hostOf(chain[i]))
+		if ip == nil {
+			// The client behind this hop is unknown; the trusted proxies are not the client.
+			return nil
+		}
+		if !containsIP(c.trusted, ip) {
+			return ip
+		}
+		leftmost = ip
+	}
+	return leftmost
+}
+
+// forwardedFor extracts the for= values of RFC 7239 Forwarded headers in order.
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, element := range splitList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || !strings.EqualFold(key, "for") {
+				continue
+			}
+			chain = append(chain, strings.Trim(value, `"`))
+		}
+	}
+	return chain
+}
+
+// splitList splits comma separated header values into trimmed elements.
+func splitList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				list = append(list, element)
+			}
+		}
+	}
+	return list
+}
+
+// hostOf strips an optional port and IPv6 brackets from an address.
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
+
+// proxyConnKey is the context key of the connection wrapped by a PROXY protocol listener.
+type proxyConnKey struct{}
+
+// proxyConnContext remembers the PROXY protocol connection behind c, if any.
+// It must not call RemoteAddr, which would read the header in the accept loop.
+func proxyConnContext(ctx context.Context, c net.Conn) context.Context {
+	if tc, ok := c.(*tls.Conn); ok {
+		c = tc.NetConn()
+	}
+	if pc, ok := c.(*proxyConn); ok {
+		return context.WithValue(ctx, proxyConnKey{}, pc)
+	}
+	return ctx
+}
+
+// proxiedAddr returns the client address conveyed by a PROXY header for the connection of ctx.
+func proxiedAddr(ctx context.Context) net.Addr {
+	pc, ok := ctx.Value(proxyConnKey{}).(*proxyConn)
+	if !ok {
+		return nil
+	}
+	pc.init()
+	return pc.remote
+}
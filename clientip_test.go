@@ -0,0 +1,90 @@
+package streamgo
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{"no headers", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"untrusted peer ignores headers", "203.0.113.7:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"},
+		{"x-forwarded-for right-most untrusted", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.5, 10.0.0.2"}, "203.0.113.5"},
+		{"x-forwarded-for all trusted", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"forwarded", "10.0.0.1:1234", map[string]string{"Forwarded": "for=203.0.113.5;proto=https, for=10.0.0.2"}, "203.0.113.5"},
+		{"forwarded ipv6", "10.0.0.1:1234", map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711"`}, "2001:db8:cafe::17"},
+		{"forwarded before x-forwarded-for", "10.0.0.1:1234", map[string]string{"Forwarded": "for=203.0.113.5", "X-Forwarded-For": "198.51.100.1"}, "203.0.113.5"},
+		{"forwarded unknown hop falls through", "10.0.0.1:1234", map[string]string{"Forwarded": "for=unknown, for=10.0.0.2", "X-Real-IP": "203.0.113.9"}, "203.0.113.9"},
+		{"forwarded obfuscated hop falls back to peer", "10.0.0.1:1234", map[string]string{"Forwarded": "for=_hidden, for=10.0.0.2"}, "10.0.0.1"},
+		{"x-forwarded-for garbage hop falls back to peer", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "nonsense, 10.0.0.2"}, "10.0.0.1"},
+		{"x-real-ip", "10.0.0.1:1234", map[string]string{"X-Real-IP": "203.0.113.9"}, "203.0.113.9"},
+		{"cf-connecting-ip", "10.0.0.1:1234", map[string]string{"CF-Connecting-IP": "203.0.113.10"}, "203.0.113.10"},
+		{"trusted ipv6 peer", "[2001:db8::1]:1234", map[string]string{"X-Forwarded-For": "2001:db8::99"}, "2001:db8::99"},
+		{"untrusted ipv6 neighbour", "[2001:db8::2]:1234", map[string]string{"X-Forwarded-For": "2001:db8::99"}, "2001:db8::2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.peer
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := resolver.Resolve(r); got != tt.want {
+				t.Errorf("Resolve = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPRequestIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.5, 10.0.0.2")
+
+	// Without trusted proxies, IP is ClientIP, which is the peer without a resolver.
+	request := &HTTPRequest{HTTP: r}
+	if got := request.IP(nil); got != "10.0.0.1" {
+		t.Errorf("IP(nil) = %q, want the peer", got)
+	}
+
+	request = &HTTPRequest{HTTP: r}
+	if got := request.IP([]string{"10.0.0.0/8"}); got != "203.0.113.5" {
+		t.Errorf("IP(trusted) = %q, want 203.0.113.5", got)
+	}
+
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request = &HTTPRequest{HTTP: r, ipResolver: resolver}
+	if got := request.IP(nil); got != "203.0.113.5" {
+		t.Errorf("IP(nil) with resolver = %q, want 203.0.113.5", got)
+	}
+}
+
+func TestTrustedProxyNetworks(t *testing.T) {
+	list := []string{"10.0.0.0/8", "bogus", "192.0.2.1"}
+
+	networks := trustedProxyNetworks(list)
+	if len(networks) != 2 {
+		t.Fatalf("networks = %v, want the two valid entries", networks)
+	}
+	// The same list is served from the cache instead of being parsed again.
+	if again := trustedProxyNetworks(slices.Clone(list)); &again[0] != &networks[0] {
+		t.Error("same list was parsed again")
+	}
+	if other := trustedProxyNetworks([]string{"10.0.0.0/8"}); len(other) != 1 {
+		t.Errorf("networks of another list = %v", other)
+	}
+}
@@ -0,0 +1,204 @@
+package streamgo
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrInvalidConcurrencyLimit = errors.New("invalid concurrency limit")
+
+// ConcurrencyLimit caps how many requests run at once.
+// Requests over the cap wait in a bounded queue; when the queue is full or the wait
+// times out they are answered with 503 Service Unavailable and a Retry-After header.
+//
+// Set it on Path.ConcurrencyLimit for an endpoint, where Include children inherit it and
+// share its slots unless they set their own, or on Server.ConcurrencyLimit for every request.
+// A WebSocket connection holds a slot of its route's limit until it closes;
+// Server.ConcurrencyLimit does not count WebSocket connections.
+type ConcurrencyLimit struct {
+	// MaxInFlight is the number of requests allowed to run at once.
+	MaxInFlight int
+
+	// MaxQueue is the number of requests allowed to wait for a slot. Zero rejects right away.
+	MaxQueue int
+
+	// QueueTimeout bounds how long a request waits for a slot.
+	// When zero, it waits until the client goes away.
+	QueueTimeout time.Duration
+
+	// RetryAfter is sent with rejected requests. It defaults to one second.
+	RetryAfter time.Duration
+
+	// TargetLatency enables adaptive shedding when positive: while the average handler
+	// latency exceeds it, the number of concurrent requests is lowered towards MinInFlight,
+	// and raised back towards MaxInFlight once latency recovers.
+	TargetLatency time.Duration
+
+	// MinInFlight is the lowest concurrency adaptive shedding goes down to. It defaults to 1.
+	MinInFlight int
+
+	mu       sync.Mutex
+	once     sync.Once
+	err      error
+	inFlight int
+	waiters  list.List
+
+	// limit is the current concurrency, MaxInFlight unless adaptive shedding lowered it.
+	limit       float64
+	latency     time.Duration
+	lastDecline time.Time
+}
+
+// validate reports a limit that cannot admit any request.
+func (c *ConcurrencyLimit) validate() error {
+	if c.MaxInFlight <= 0 || c.MaxQueue < 0 || c.MinInFlight < 0 || c.MinInFlight > c.MaxInFlight {
+		return fmt.Errorf("%w: max in flight %d, max queue %d, min in flight %d",
+			ErrInvalidConcurrencyLimit, c.MaxInFlight, c.MaxQueue, c.MinInFlight)
+	}
+	return nil
+}
+
+// init validates the limit and sets up its state once, when the limit is installed
+// by BuildPaths, ListenAll, Compile or ConcurrencyLimitMiddleware, and reports the
+// validation error on every call.
+func (c *ConcurrencyLimit) init() error {
+	c.once.Do(func() {
+		c.err = c.validate()
+		c.limit = float64(c.MaxInFlight)
+	})
+	return c.err
+}
+
+// InFlight returns the number of requests currently holding a slot.
+func (c *ConcurrencyLimit) InFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inFlight
+}
+
+// Limit returns the current concurrency, lower than MaxInFlight while adaptive shedding is active.
+func (c *ConcurrencyLimit) Limit() int {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.limit)
+}
+
+// acquire takes a slot, waiting in the queue when none is free.
+// It reports false when the request was shed. c must have passed init.
+func (c *ConcurrencyLimit) acquire(ctx context.Context) bool {
+	c.mu.Lock()
+	if c.inFlight < int(c.limit) && c.waiters.Len() == 0 {
+		c.inFlight++
+		c.mu.Unlock()
+		return true
+	}
+	if c.waiters.Len() >= c.MaxQueue {
+		c.mu.Unlock()
+		return false
+	}
+	ready := make(chan struct{})
+	elem := c.waiters.PushBack(ready)
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if c.QueueTimeout > 0 {
+		timer := time.NewTimer(c.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ready:
+		return true
+	case <-ctx.Done():
+	case <-timeout:
+	}
+
+	c.mu.Lock()
+	select {
+	case <-ready:
+		// The slot was handed over while giving up; pass it on.
+		c.mu.Unlock()
+		c.release(0)
+	default:
+		c.waiters.Remove(elem)
+		c.mu.Unlock()
+	}
+	return false
+}
+
+// release returns a slot held for elapsed and hands it to the next waiter.
+// elapsed is zero for slots that served no request.
+func (c *ConcurrencyLimit) release(elapsed time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.TargetLatency > 0 && elapsed > 0 {
+		c.adapt(elapsed)
+	}
+
+	c.inFlight--
+	for c.waiters.Len() > 0 && c.inFlight < int(c.limit) {
+		ready := c.waiters.Remove(c.waiters.Front()).(chan struct{})
+		c.inFlight++
+		close(ready)
+	}
+}
+
+// adapt updates the average latency and moves the limit: down by a tenth at most once
+// per average latency while above TargetLatency, and up by about one slot per
+// limit requests otherwise.
+func (c *ConcurrencyLimit) adapt(elapsed time.Duration) {
+	if c.latency == 0 {
+		c.latency = elapsed
+	} else {
+		c.latency = (c.latency*4 + elapsed) / 5
+	}
+
+	minimum := float64(max(c.MinInFlight, 1))
+	now := time.Now()
+	if c.latency > c.TargetLatency {
+		if now.Sub(c.lastDecline) >= c.latency {
+			c.limit = max(minimum, c.limit*0.9)
+			c.lastDecline = now
+		}
+		return
+	}
+	c.limit = min(float64(c.MaxInFlight), c.limit+1/c.limit)
+}
+
+// reject answers a shed request.
+func (c *ConcurrencyLimit) reject(response *HTTPResponse) {
+	retryAfter := c.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	response.Writer.Header().Set("Retry-After", seconds(retryAfter))
+	response.Status(http.StatusServiceUnavailable)
+}
+
+// ConcurrencyLimitMiddleware runs requests within c and sheds the rest with 503 Service Unavailable.
+// Path.ConcurrencyLimit installs it automatically. It returns an error wrapping
+// ErrInvalidConcurrencyLimit when c cannot admit any request.
+func ConcurrencyLimitMiddleware[Payload any](c *ConcurrencyLimit) (Middleware[Payload], error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return func(next HandlerFunc[Payload]) HandlerFunc[Payload] {
+		return func(request *HTTPRequest, response *HTTPResponse, payload Payload) {
+			if !c.acquire(request.HTTP.Context()) {
+				c.reject(response)
+				return
+			}
+			start := time.Now()
+			defer func() { c.release(time.Since(start)) }()
+			next(request, response, payload)
+		}
+	}, nil
+}
@@ -0,0 +1,99 @@
+package streamgo
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newConcurrencyServer(t *testing.T, release <-chan struct{}) *Server[string] {
+	t.Helper()
+
+	return newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		if payload == "slow" {
+			<-release
+		}
+		response.HTML("ok")
+	}, []Path[string]{{Name: "/fast"}, {Name: "/slow", Payload: "slow"}})
+}
+
+func TestServerConcurrencyLimitSheds(t *testing.T) {
+	release := make(chan struct{})
+	s := newConcurrencyServer(t, release)
+	s.ConcurrencyLimit = &ConcurrencyLimit{MaxInFlight: 1}
+	s.Compile()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serve(s, "GET", "/slow")
+	}()
+	// Wait for the slow request to hold the only slot.
+	for s.ConcurrencyLimit.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := do(s, httptest.NewRequest("GET", "/fast", nil))
+	if w.Code != 503 || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("request over the limit = %d, Retry-After %q, want 503 and 1", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	<-done
+	if w := do(s, httptest.NewRequest("GET", "/fast", nil)); w.Code != 200 {
+		t.Errorf("request after release = %d, want 200", w.Code)
+	}
+}
+
+func TestInvalidConcurrencyLimit(t *testing.T) {
+	invalid := &ConcurrencyLimit{MaxInFlight: 0}
+
+	if _, err := ConcurrencyLimitMiddleware[string](invalid); !errors.Is(err, ErrInvalidConcurrencyLimit) {
+		t.Errorf("ConcurrencyLimitMiddleware = %v, want %v", err, ErrInvalidConcurrencyLimit)
+	}
+
+	built := NewServer[string](NewRegexOptions(1))
+	built.ConcurrencyLimit = invalid
+	if err := built.BuildPaths([]Path[string]{{Name: "/"}}, ""); !errors.Is(err, ErrInvalidConcurrencyLimit) {
+		t.Errorf("BuildPaths = %v, want %v", err, ErrInvalidConcurrencyLimit)
+	}
+
+	// A limit set after BuildPaths is reported by Compile and fails every request.
+	var log bytes.Buffer
+	s := newConcurrencyServer(t, nil)
+	s.Logger = slog.New(slog.NewTextHandler(&log, nil))
+	s.ConcurrencyLimit = invalid
+	s.Compile()
+	if !strings.Contains(log.String(), "invalid concurrency limit") {
+		t.Errorf("log = %q, want the validation error", log.String())
+	}
+	for range 2 {
+		if w := do(s, httptest.NewRequest("GET", "/fast", nil)); w.Code != 500 {
+			t.Errorf("status = %d, want 500", w.Code)
+		}
+	}
+}
+
+func TestServerConcurrencyLimitSkipsWebSockets(t *testing.T) {
+	inFlight := -1
+	s := newTestServer(t, answerOK, []Path[string]{{Name: "/ws", WebSocket: WS{Upgrader: &websocket.Upgrader{}}}})
+	s.ConcurrencyLimit = &ConcurrencyLimit{MaxInFlight: 1}
+	s.WebSocketHandler = func(request *HTTPRequest, response *HTTPResponse, payload string, upgrader *websocket.Upgrader) {
+		inFlight = s.ConcurrencyLimit.InFlight()
+	}
+	s.Compile()
+
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	do(s, r)
+	if inFlight != 0 {
+		t.Errorf("slots held by a WebSocket handler = %d, want 0", inFlight)
+	}
+}
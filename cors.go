@@ -0,0 +1,220 @@
+package streamgo
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCORSCredentialsWildcard is returned for a policy that allows credentials from every origin,
+// which would let any site make authenticated requests and read the answers.
+var ErrCORSCredentialsWildcard = errors.New(`AllowCredentials cannot be used with the "*" origin`)
+
+// CORSPolicy configures cross-origin resource sharing for a route tree.
+type CORSPolicy struct {
+	// AllowedOrigins lists exact origins such as "https://app.example.com".
+	// "*" allows every origin.
+	AllowedOrigins []string
+
+	// AllowedOriginPatterns lists origins with wildcards, where '*' matches
+	// one or more characters other than '/', such as "https://*.example.com".
+	AllowedOriginPatterns []string
+
+	// AllowOriginFunc allows origins that are not listed when it returns true.
+	AllowOriginFunc func(origin string) bool
+
+	// AllowedMethods lists the methods allowed in preflight requests.
+	// It defaults to the methods the route answers.
+	AllowedMethods []HTTPMethod
+
+	// AllowedHeaders lists the request headers allowed in preflight requests.
+	// When empty, or when it contains "*", the requested headers are allowed.
+	AllowedHeaders []string
+
+	// ExposedHeaders lists the response headers readable by the client.
+	ExposedHeaders []string
+
+	// AllowCredentials allows cookies and HTTP authentication.
+	// It cannot be combined with the "*" origin; list the trusted origins instead.
+	AllowCredentials bool
+
+	// MaxAge is how long a preflight response may be cached.
+	MaxAge time.Duration
+}
+
+// corsConfig is a CORSPolicy prepared for request handling.
+type corsConfig struct {
+	policy         *CORSPolicy
+	anyOrigin      bool
+	origins        map[string]bool
+	patterns       []*regexp.Regexp
+	methods        string
+	anyHeader      bool
+	headers        map[string]bool
+	exposedHeaders string
+	maxAge         string
+}
+
+// compileCORS prepares policy, returning nil for a nil policy.
+func compileCORS(policy *CORSPolicy) (*corsConfig, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	c := &corsConfig{
+		policy:         policy,
+		origins:        map[string]bool{},
+		headers:        map[string]bool{},
+		anyHeader:      len(policy.AllowedHeaders) == 0,
+		exposedHeaders: strings.Join(policy.ExposedHeaders, ", "),
+	}
+
+	for _, origin := range policy.AllowedOrigins {
+		if origin == "*" {
+			if policy.AllowCredentials {
+				return nil, ErrCORSCredentialsWildcard
+			}
+			c.anyOrigin = true
+			continue
+		}
+		c.origins[strings.ToLower(origin)] = true
+	}
+
+	for _, pattern := range policy.AllowedOriginPatterns {
+		expr := strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(pattern)), `\*`, `[^/]+`)
+		c.patterns = append(c.patterns, regexp.MustCompile("^"+expr+"$"))
+	}
+
+	methods := make([]string, len(policy.AllowedMethods))
+	for i, method := range policy.AllowedMethods {
+		methods[i] = string(method)
+	}
+	c.methods = strings.Join(methods, ", ")
+
+	for _, header := range policy.AllowedHeaders {
+		if header == "*" {
+			c.anyHeader = true
+			continue
+		}
+		c.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	if policy.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(policy.MaxAge / time.Second))
+	}
+	return c, nil
+}
+
+func (c *corsConfig) allowsOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+
+	lower := strings.ToLower(origin)
+	if c.origins[lower] {
+		return true
+	}
+	for _, pattern := range c.patterns {
+		if pattern.MatchString(lower) {
+			return true
+		}
+	}
+	return c.policy.AllowOriginFunc != nil && c.policy.AllowOriginFunc(origin)
+}
+
+// allowsHeaders reports whether every header of an Access-Control-Request-Headers value is allowed.
+func (c *corsConfig) allowsHeaders(requested string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !c.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// echoesOrigin reports whether responses carry the request origin rather than "*".
+func (c *corsConfig) echoesOrigin() bool {
+	return !c.anyOrigin
+}
+
+// setOrigin writes the headers shared by preflight and actual responses.
+func (c *corsConfig) setOrigin(h http.Header, origin string) {
+	if c.echoesOrigin() {
+		h.Set("Access-Control-Allow-Origin", origin)
+	} else {
+		h.Set("Access-Control-Allow-Origin", "*")
+	}
+	if c.policy.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == string(OPTIONS) && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// corsPreflight answers a preflight request for path.
+// Disallowed requests get no CORS headers, which makes the browser reject them.
+func corsPreflight[Payload any](c *corsConfig, path *Path[Payload]) HandlerFunc[Payload] {
+	return func(request *HTTPRequest, response *HTTPResponse, payload Payload) {
+		h := response.Writer.Header()
+		h.Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+
+		origin := request.Header("Origin")
+		method := request.Header("Access-Control-Request-Method")
+		requested := request.Header("Access-Control-Request-Headers")
+
+		methods := c.methods
+		allowed := false
+		if len(c.policy.AllowedMethods) > 0 {
+			for _, m := range c.policy.AllowedMethods {
+				allowed = allowed || string(m) == method
+			}
+		} else {
+			methods = path.Allow()
+			allowed = path.IsMethodAllowed(method) || (method == string(HEAD) && path.AllowsAutoHead())
+		}
+
+		if !allowed || !c.allowsOrigin(origin) || !c.allowsHeaders(requested) {
+			response.Status(http.StatusNoContent)
+			return
+		}
+
+		c.setOrigin(h, origin)
+		h.Set("Access-Control-Allow-Methods", methods)
+		if requested != "" {
+			h.Set("Access-Control-Allow-Headers", requested)
+		}
+		if c.maxAge != "" {
+			h.Set("Access-Control-Max-Age", c.maxAge)
+		}
+		response.Status(http.StatusNoContent)
+	}
+}
+
+// applyCORS adds the CORS headers of an actual cross-origin request.
+func (c *corsConfig) applyCORS(r *http.Request, h http.Header) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	if c.echoesOrigin() {
+		h.Add("Vary", "Origin")
+	}
+	if !c.allowsOrigin(origin) {
+		return
+	}
+
+	c.setOrigin(h, origin)
+	if c.exposedHeaders != "" {
+		h.Set("Access-Control-Expose-Headers", c.exposedHeaders)
+	}
+}
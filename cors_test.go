@@ -0,0 +1,144 @@
+package streamgo
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newCORSServer(t *testing.T) *Server[string] {
+	return newTestServer(t, answerOK, []Path[string]{
+		{Name: "/api", HTTP: HTTP{Methods: map[HTTPMethod]bool{GET: true, PUT: true}}, CORS: &CORSPolicy{
+			AllowedOrigins:        []string{"https://app.example"},
+			AllowedOriginPatterns: []string{"https://*.example.org"},
+			AllowedHeaders:        []string{"X-Token"},
+			ExposedHeaders:        []string{"X-Total", "X-Page"},
+			AllowCredentials:      true,
+			MaxAge:                10 * time.Minute,
+		}},
+		{Name: "/public", CORS: &CORSPolicy{AllowedOrigins: []string{"*"}}},
+		{Name: "/plain"},
+	})
+}
+
+func TestCORSPreflight(t *testing.T) {
+	s := newCORSServer(t)
+
+	tests := []struct {
+		name    string
+		url     string
+		origin  string
+		method  string
+		headers string
+		want    map[string]string
+	}{
+		{"allowed", "/api", "https://app.example", "PUT", "x-token", map[string]string{
+			"Access-Control-Allow-Origin":      "https://app.example",
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Allow-Methods":     "GET, HEAD, OPTIONS, PUT",
+			"Access-Control-Allow-Headers":     "x-token",
+			"Access-Control-Max-Age":           "600",
+		}},
+		{"pattern", "/api", "https://eu.example.org", "GET", "", map[string]string{
+			"Access-Control-Allow-Origin": "https://eu.example.org",
+		}},
+		{"disallowed origin", "/api", "https://evil.example", "GET", "", map[string]string{
+			"Access-Control-Allow-Origin": "",
+		}},
+		{"pattern spans no slash", "/api", "https://evil.com/.example.org", "GET", "", map[string]string{
+			"Access-Control-Allow-Origin": "",
+		}},
+		{"disallowed method", "/api", "https://app.example", "DELETE", "", map[string]string{
+			"Access-Control-Allow-Origin": "",
+		}},
+		{"disallowed header", "/api", "https://app.example", "GET", "X-Token, X-Other", map[string]string{
+			"Access-Control-Allow-Origin": "",
+		}},
+		{"wildcard", "/public", "https://any.example", "GET", "", map[string]string{
+			"Access-Control-Allow-Origin":      "*",
+			"Access-Control-Allow-Credentials": "",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("OPTIONS", tt.url, nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			w := do(s, r)
+
+			if w.Code != 204 {
+				t.Errorf("status = %d, want 204", w.Code)
+			}
+			if w.Header().Get("Vary") == "" {
+				t.Error("preflight response has no Vary header")
+			}
+			for name, want := range tt.want {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	s := newCORSServer(t)
+
+	tests := []struct {
+		name   string
+		url    string
+		origin string
+		allow  string
+		expose string
+		vary   string
+	}{
+		{"allowed", "/api", "https://app.example", "https://app.example", "X-Total, X-Page", "Origin"},
+		// Caches must not hand the answer to an allowed origin to other origins.
+		{"disallowed", "/api", "https://evil.example", "", "", "Origin"},
+		{"same origin", "/api", "", "", "", ""},
+		{"wildcard", "/public", "https://any.example", "*", "", ""},
+		{"no policy", "/plain", "https://app.example", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := do(s, r)
+
+			if w.Body.String() != "ok" {
+				t.Errorf("body = %q, want the handler's answer", w.Body.String())
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allow)
+			}
+			if got := w.Header().Get("Access-Control-Expose-Headers"); got != tt.expose {
+				t.Errorf("Access-Control-Expose-Headers = %q, want %q", got, tt.expose)
+			}
+			if got := w.Header().Get("Vary"); got != tt.vary {
+				t.Errorf("Vary = %q, want %q", got, tt.vary)
+			}
+		})
+	}
+}
+
+func TestCORSCredentialsWithWildcard(t *testing.T) {
+	policy := &CORSPolicy{AllowedOrigins: []string{"https://app.example", "*"}, AllowCredentials: true}
+
+	s := NewServer[string](NewRegexOptions(1))
+	s.CORS = policy
+	if err := s.BuildPaths([]Path[string]{{Name: "/"}}, ""); !errors.Is(err, ErrCORSCredentialsWildcard) {
+		t.Errorf("BuildPaths with Server.CORS = %v, want %v", err, ErrCORSCredentialsWildcard)
+	}
+
+	s = NewServer[string](NewRegexOptions(1))
+	err := s.BuildPaths([]Path[string]{{Name: "/api", CORS: policy}}, "")
+	if !errors.Is(err, ErrCORSCredentialsWildcard) {
+		t.Errorf("BuildPaths with Path.CORS = %v, want %v", err, ErrCORSCredentialsWildcard)
+	}
+}
@@ -0,0 +1,87 @@
+package streamgo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server answering paths with handler.
+// configure runs before BuildPaths, for options that routes pick up when they are built.
+// Unmatched requests are answered with "404" and disallowed methods with "405".
+func newTestServer(t *testing.T, handler HandlerFunc[string], paths []Path[string], configure ...func(s *Server[string])) *Server[string] {
+	t.Helper()
+
+	s := NewServer[string](NewRegexOptions(1))
+	s.HTTPHandler = handler
+	s.HTTPHandle404 = func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		response.Status(http.StatusNotFound)
+		response.HTML("404")
+	}
+	s.HTTPHandle405 = func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		response.Status(http.StatusMethodNotAllowed)
+		response.HTML("405")
+	}
+	for _, c := range configure {
+		c(&s)
+	}
+	if err := s.BuildPaths(paths, ""); err != nil {
+		t.Fatalf("BuildPaths: %v", err)
+	}
+	s.Compile()
+	return &s
+}
+
+// answerOK is a handler answering "ok".
+func answerOK(request *HTTPRequest, response *HTTPResponse, payload string) {
+	response.HTML("ok")
+}
+
+// do serves r and returns the recorded response.
+func do(s *Server[string], r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// serve requests url with method and returns the response body.
+func serve(s *Server[string], method, url string) string {
+	return do(s, httptest.NewRequest(method, url, nil)).Body.String()
+}
+
+// listenLoopback serves s through ListenAll with listeners, opening a loopback TCP listener
+// for each one without a Listener. It returns their addresses and a function that stops
+// the server and reports ListenAll's error.
+func listenLoopback(t *testing.T, s *Server[string], listeners ...ListenerConfig) ([]string, func() error) {
+	t.Helper()
+
+	addrs := make([]string, len(listeners))
+	for i := range listeners {
+		if listeners[i].Listener == nil {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			listeners[i].Listener = ln
+		}
+		addrs[i] = listeners[i].Listener.Addr().String()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAll(ctx, listeners...) }()
+
+	return addrs, func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Error("ListenAll did not return")
+			return nil
+		}
+	}
+}
@@ -2,6 +2,7 @@ package streamgo
 
 import (
 	"bytes"
+	"crypto/x509"
 	"errors"
 	"io"
 	"mime/multipart"
@@ -16,6 +17,15 @@ import (
 type HTTPRequest struct {
 	HTTP   *http.Request
 	Params map[string]string
+
+	// clientIP caches the result of ClientIP.
+	clientIP string
+
+	// ipResolver is the server's ClientIPResolver, if one is configured.
+	ipResolver *ClientIPResolver
+
+	// requestID is the ID assigned by Server.RequestID.
+	requestID string
 }
 
 var (
@@ -60,63 +70,124 @@ func (r *HTTPRequest) Header(name string) string {
 	return r.HTTP.Header.Get(name)
 }
 
+// ClientIP returns the client IP determined by the server's ClientIPResolver.
+// Without a resolver it is the peer address. The result is cached on the request.
+func (r *HTTPRequest) ClientIP() string {
+	if r.clientIP != "" {
+		return r.clientIP
+	}
+
+	if r.ipResolver != nil {
+		r.clientIP = r.ipResolver.Resolve(r.HTTP)
+	} else {
+		r.clientIP = hostOf(r.HTTP.RemoteAddr)
+	}
+	return r.clientIP
+}
+
 // IP returns the real client IP considering X-Forwarded-For, X-Real-IP, and RemoteAddr.
 // trustedProxies is a list of IPs or CIDRs for proxies we trust.
+//
+// When trustedProxies is empty, it returns ClientIP instead. This is a change from
+// earlier versions, which walked X-Forwarded-For and X-Real-IP without any trusted proxy:
+// without a Server.ClientIPResolver, IP(nil) now returns the peer address, which is the
+// proxy's address behind a proxy.
+//
+// Deprecated: Configure Server.ClientIPResolver, which parses the trusted proxies once,
+// and use ClientIP.
 func (r *HTTPRequest) IP(trustedProxies []string) string {
-    // Parse trusted proxies into net.IPNet
-    var trustedNets []*net.IPNet
-    for _, cidr := range trustedProxies {
-        if _, network, err := net.ParseCIDR(cidr); err == nil {
-            trustedNets = append(trustedNets, network)
-        } else if ip := net.ParseIP(cidr); ip != nil {
-            trustedNets = append(trustedNets, &net.IPNet{
-                IP:   ip,
-                Mask: net.CIDRMask(32, 32),
-            })
-        }
-    }
-
-    // Helper: check if IP is in trusted proxies
-    isTrusted := func(ip net.IP) bool {
-        for _, net := range trustedNets {
-            if net.Contains(ip) {
-                return true
-            }
-        }
-        return false
-    }
-
-    // 1. X-Forwarded-For
-    forwarded := r.HTTP.Header.Get("X-Forwarded-For")
-    if forwarded != "" {
-        ips := strings.Split(forwarded, ",")
-        // Traverse from right to left: last IP is closest proxy
-        for i := len(ips) - 1; i >= 0; i-- {
-            ip := strings.TrimSpace(ips[i])
-            parsedIP := net.ParseIP(ip)
-            if parsedIP == nil {
-                continue
-            }
-            if !isTrusted(parsedIP) {
-                return ip // First non-trusted IP is real client
-            }
-        }
-    }
-
-    // 2. X-Real-IP
-    realIP := r.HTTP.Header.Get("X-Real-IP")
-    if realIP != "" {
-        if parsedIP := net.ParseIP(realIP); parsedIP != nil && !isTrusted(parsedIP) {
-            return realIP
-        }
-    }
-
-    // 3. Fallback: RemoteAddr
-    ip, _, err := net.SplitHostPort(r.HTTP.RemoteAddr)
-    if err != nil {
-        return r.HTTP.RemoteAddr
-    }
-    return ip
+	if len(trustedProxies) == 0 {
+		return r.ClientIP()
+	}
+
+	trustedNets := trustedProxyNetworks(trustedProxies)
+
+	// 1. X-Forwarded-For
+	forwarded := r.HTTP.Header.Get("X-Forwarded-For")
+	if forwarded != "" {
+		ips := strings.Split(forwarded, ",")
+		// Traverse from right to left: last IP is closest proxy
+		for i := len(ips) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(ips[i])
+			parsedIP := net.ParseIP(ip)
+			if parsedIP == nil {
+				continue
+			}
+			if !containsIP(trustedNets, parsedIP) {
+				return ip // First non-trusted IP is real client
+			}
+		}
+	}
+
+	// 2. X-Real-IP
+	realIP := r.HTTP.Header.Get("X-Real-IP")
+	if realIP != "" {
+		if parsedIP := net.ParseIP(realIP); parsedIP != nil && !containsIP(trustedNets, parsedIP) {
+			return realIP
+		}
+	}
+
+	// 3. Fallback: RemoteAddr
+	ip, _, err := net.SplitHostPort(r.HTTP.RemoteAddr)
+	if err != nil {
+		return r.HTTP.RemoteAddr
+	}
+	return ip
+}
+
+// maxTrustedProxyLists bounds the number of distinct lists cached by trustedProxyNetworks.
+const maxTrustedProxyLists = 64
+
+var trustedProxyCache = struct {
+	sync.Mutex
+	networks map[string][]*net.IPNet
+}{networks: map[string][]*net.IPNet{}}
+
+// trustedProxyNetworks parses the trusted proxies passed to IP, remembering the result
+// for each distinct list so repeated calls with the same list parse it only once.
+// Invalid entries are skipped, as they always were.
+func trustedProxyNetworks(trustedProxies []string) []*net.IPNet {
+	key := strings.Join(trustedProxies, "\x00")
+
+	trustedProxyCache.Lock()
+	defer trustedProxyCache.Unlock()
+
+	if networks, ok := trustedProxyCache.networks[key]; ok {
+		return networks
+	}
+
+	var networks []*net.IPNet
+	for _, cidr := range trustedProxies {
+		if parsed, err := parseTrustedNetworks([]string{cidr}); err == nil {
+			networks = append(networks, parsed...)
+		}
+	}
+
+	// Callers building a new list per request would grow the cache forever.
+	if len(trustedProxyCache.networks) >= maxTrustedProxyLists {
+		clear(trustedProxyCache.networks)
+	}
+	trustedProxyCache.networks[key] = networks
+	return networks
+}
+
+// ClientCertificate returns the verified client certificate of a mutual TLS connection.
+// It returns nil when the connection is not TLS or no client certificate was verified.
+func (r *HTTPRequest) ClientCertificate() *x509.Certificate {
+	chains := r.ClientCertificateChains()
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+	return chains[0][0]
+}
+
+// ClientCertificateChains returns the verified chains of the client certificate,
+// each starting with the client certificate and ending with a trusted CA.
+func (r *HTTPRequest) ClientCertificateChains() [][]*x509.Certificate {
+	if r.HTTP.TLS == nil {
+		return nil
+	}
+	return r.HTTP.TLS.VerifiedChains
 }
 
 func (r *HTTPRequest) Method() string {
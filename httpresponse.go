@@ -1,10 +1,11 @@
 package streamgo
 
 import (
-	"io"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/gorilla/websocket"
 	jsoniter "github.com/json-iterator/go"
 )
 
@@ -17,24 +18,73 @@ var (
 
 type HTTPResponse struct {
 	Writer http.ResponseWriter // Pointer yerine direkt interface'i kullan!
+
+	// webSockets is the server's tracker for connections upgraded through UpgradeWebSocket.
+	webSockets *webSocketTracker
+
+	// upgraded lists the connections upgraded through this response.
+	upgraded []*websocket.Conn
+
+	// metrics is the server's metrics collector, if one is configured.
+	metrics *Metrics
+
+	// rw records the status and size of the response. Writer is set to it as well.
+	rw *responseWriter
 }
 
+// recorder returns the writer that tracks this response, wrapping Writer on first use
+// when the response was not created by the server.
+func (resp *HTTPResponse) recorder() *responseWriter {
+	if resp.rw == nil {
+		resp.rw = newResponseWriter(resp.Writer, time.Now())
+		resp.Writer = resp.rw
+	}
+	return resp.rw
+}
+
+// Status sends the status code. Only the first call has an effect.
 func (resp *HTTPResponse) Status(i int) {
+	resp.recorder().WriteHeader(i)
+}
+
+// Written reports whether the headers were already sent, or the connection was hijacked.
+func (resp *HTTPResponse) Written() bool {
+	return resp.recorder().wroteHeader
+}
+
+// StatusCode returns the status that was sent, or 0 if nothing was sent yet.
+func (resp *HTTPResponse) StatusCode() int {
+	return resp.recorder().status
+}
+
+// BytesWritten returns the number of body bytes written.
+func (resp *HTTPResponse) BytesWritten() int64 {
+	return resp.recorder().written
+}
 
-	resp.Writer.WriteHeader(i)
+// Hijacked reports whether the connection was taken over, for example by a WebSocket upgrade.
+func (resp *HTTPResponse) Hijacked() bool {
+	return resp.recorder().hijacked
+}
+
+// TimeToFirstByte returns the time from the start of the request until the headers were sent,
+// or 0 if nothing was sent yet.
+func (resp *HTTPResponse) TimeToFirstByte() time.Duration {
+	rw := resp.recorder()
+	if rw.firstByte.IsZero() {
+		return 0
+	}
+	return rw.firstByte.Sub(rw.start)
 }
 
 func (resp *HTTPResponse) HTML(s string) (int, error) {
 	h := resp.Writer.Header()
 	h[contentType] = contentTypeHTML
-	if sw, ok := resp.Writer.(io.StringWriter); ok {
-		return sw.WriteString(s)
-	}
-	return resp.Writer.Write([]byte(s))
+	return resp.recorder().WriteString(s)
 }
 
 func (resp *HTTPResponse) Write(v []byte) (int, error) {
-	return resp.Writer.Write(v)
+	return resp.recorder().Write(v)
 }
 
 func (resp *HTTPResponse) JSON(v any) (int, error) {
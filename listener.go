@@ -0,0 +1,243 @@
+package streamgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var ErrDuplicateListener = errors.New("duplicate listener name")
+
+// ListenerConfig describes one listener served by ListenAll.
+type ListenerConfig struct {
+	// Name identifies the listener and is reported by HTTPRequest.Listener.
+	// It defaults to the listener address.
+	Name string
+
+	// Network is "tcp", "tcp4", "tcp6" or "unix". It defaults to "tcp".
+	Network string
+
+	// Address is the TCP address or the unix socket path to listen on.
+	Address string
+
+	// Listener is an already open listener to serve instead of Network and Address.
+	// It is closed on shutdown, but a unix socket file behind it is left in place.
+	// When it is nil, a listener inherited under Name through socket activation
+	// is preferred over binding Address, see InheritedListeners.
+	Listener net.Listener
+
+	// TLS makes the listener serve TLS when set.
+	TLS *TLSOptions
+
+	// Unix configures the socket file of a unix listener.
+	Unix *UnixSocketOptions
+
+	// ProxyProtocol accepts PROXY protocol headers from trusted load balancers when set.
+	ProxyProtocol *ProxyProtocolOptions
+
+	// Options overrides Server.Options for this listener when set.
+	Options *ServerOptions
+}
+
+// listenerNameKey is the context key under which the listener name is stored.
+type listenerNameKey struct{}
+
+// Listener returns the name of the listener the request arrived on.
+// It is empty when the server is not driven by Listen or ListenAll.
+func (r *HTTPRequest) Listener() string {
+	name, _ := r.HTTP.Context().Value(listenerNameKey{}).(string)
+	return name
+}
+
+// Listen serves on the TCP address and the Unix socket path, either of which may be empty.
+// The listeners are named "tcp" and "unix". See ListenAll.
+func (s *Server[PayloadType]) Listen(ctx context.Context, addr, unixSocketPath string) error {
+	var listeners []ListenerConfig
+	if addr != "" {
+		listeners = append(listeners, ListenerConfig{Name: "tcp", Network: "tcp", Address: addr, TLS: s.TLS})
+	}
+	if unixSocketPath != "" {
+		listeners = append(listeners, ListenerConfig{Name: "unix", Network: "unix", Address: unixSocketPath, Unix: s.UnixSocket})
+	}
+	return s.ListenAll(ctx, listeners...)
+}
+
+// ListenAll serves every configured listener.
+// It blocks until ctx is cancelled and the servers have shut down, see ShutdownTimeout,
+// or until a listener fails, in which case the remaining listeners are closed and the error is returned.
+func (s *Server[PayloadType]) ListenAll(ctx context.Context, listeners ...ListenerConfig) error {
+	if len(listeners) == 0 {
+		return ErrNoListener
+	}
+	// Defaults are filled in on a copy, so the caller's configurations stay reusable.
+	listeners = slices.Clone(listeners)
+	if s.ConcurrencyLimit != nil {
+		if err := s.ConcurrencyLimit.init(); err != nil {
+			return err
+		}
+	}
+
+	s.Compile()
+	s.webSockets.reset()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", s)
+
+	// Cancelling on return stops background work such as certificate reloading.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.TCPServer, s.UnixSocketServer, s.unixListener = nil, nil, nil
+
+	var (
+		servers       []*http.Server
+		netListens    []net.Listener
+		servedListens []net.Listener
+		socketPaths   []string
+		names         = map[string]bool{}
+	)
+
+	// Every listener is opened before any is served, so a bad configuration leaves nothing behind.
+	fail := func(err error) error {
+		for _, l := range netListens {
+			l.Close()
+		}
+		for _, p := range socketPaths {
+			removeSocket(p)
+		}
+		return err
+	}
+
+	for i := range listeners {
+		cfg := &listeners[i]
+		if cfg.Network == "" {
+			cfg.Network = "tcp"
+		}
+
+		listener, created, err := cfg.open()
+		if err != nil {
+			return fail(err)
+		}
+		netListens = append(netListens, listener)
+		if created && cfg.Network == "unix" && !strings.HasPrefix(cfg.Address, "@") {
+			socketPaths = append(socketPaths, cfg.Address)
+		}
+
+		if cfg.Name == "" {
+			cfg.Name = listener.Addr().String()
+		}
+
+		// The raw listener stays in netListens, so it can be closed and handed off.
+		served := listener
+		if cfg.ProxyProtocol != nil {
+			if served, err = newProxyListener(listener, cfg.ProxyProtocol); err != nil {
+				return fail(fmt.Errorf("configure proxy protocol %v: %w", cfg.Name, err))
+			}
+		}
+		servedListens = append(servedListens, served)
+		if names[cfg.Name] {
+			return fail(fmt.Errorf("%w: %v", ErrDuplicateListener, cfg.Name))
+		}
+		names[cfg.Name] = true
+
+		opts := &s.Options
+		if cfg.Options != nil {
+			opts = cfg.Options
+		}
+		srv := opts.newHTTPServer(cfg.Address, mux)
+
+		name := cfg.Name
+		srv.BaseContext = func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), listenerNameKey{}, name)
+		}
+
+		if cfg.ProxyProtocol != nil {
+			srv.ConnContext = proxyConnContext
+		}
+
+		if s.Metrics != nil {
+			srv.ConnState = s.Metrics.connState(name, srv.ConnState)
+		}
+
+		if cfg.TLS != nil {
+			reloader, err := newTLSReloader(cfg.TLS)
+			if err != nil {
+				return fail(fmt.Errorf("configure tls %v: %w", cfg.Name, err))
+			}
+			srv.TLSConfig = reloader.config()
+			go reloader.watch(ctx)
+		}
+
+		servers = append(servers, srv)
+		if cfg.Network == "unix" && s.UnixSocketServer == nil {
+			s.UnixSocketServer = srv
+			s.unixListener = listener
+		} else if cfg.Network != "unix" && s.TCPServer == nil {
+			s.TCPServer = srv
+		}
+	}
+
+	active := make([]activeListener, len(servers))
+	for i := range servers {
+		active[i] = activeListener{name: listeners[i].Name, listener: netListens[i]}
+	}
+	s.active.set(active)
+
+	errCh := make(chan error, len(servers))
+	for i, srv := range servers {
+		listener, name := servedListens[i], listeners[i].Name
+		go func() {
+			var err error
+			if srv.TLSConfig != nil {
+				err = srv.ServeTLS(listener, "", "")
+			} else {
+				err = srv.Serve(listener)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				errCh <- fmt.Errorf("serve %v: %w", name, err)
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Println("Server stopped due to context cancellation")
+		return s.shutdown(servers, socketPaths)
+	case err := <-errCh:
+		closeServers(servers)
+		s.removeSockets(socketPaths)
+		return err
+	}
+}
+
+// open returns the listener for cfg and whether it was created here rather than provided.
+func (cfg *ListenerConfig) open() (net.Listener, bool, error) {
+	if cfg.Listener != nil {
+		return cfg.Listener, false, nil
+	}
+
+	if cfg.Name != "" {
+		if listener, ok := claimInheritedListener(cfg.Name); ok {
+			return listener, false, nil
+		}
+	}
+
+	if cfg.Network != "unix" {
+		listener, err := net.Listen(cfg.Network, cfg.Address)
+		if err != nil {
+			return nil, false, fmt.Errorf("listen %v %v: %w", cfg.Network, cfg.Address, err)
+		}
+		return listener, true, nil
+	}
+
+	listener, err := listenUnix(cfg.Address, cfg.Unix)
+	if err != nil {
+		return nil, false, err
+	}
+	return listener, true, nil
+}
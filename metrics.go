@@ -0,0 +1,352 @@
+package streamgo
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the request duration histogram.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the upper bounds, in bytes, of the response size histogram.
+var DefaultSizeBuckets = []float64{100, 1000, 10_000, 100_000, 1_000_000, 10_000_000}
+
+// unmatchedRoute is the route label of requests that matched no Path.
+// Route patterns always start with a slash, so it cannot collide with one.
+const unmatchedRoute = "unmatched"
+
+// otherMethod is the method label of requests whose method is not an HTTPMethod,
+// so clients sending arbitrary methods cannot create new series.
+const otherMethod = "OTHER"
+
+// methodLabel returns method when it is one of the HTTPMethod constants and otherMethod otherwise.
+func methodLabel(method string) string {
+	switch HTTPMethod(method) {
+	case GET, POST, PUT, DELETE, PATCH, OPTIONS, HEAD, TRACE, CONNECT:
+		return method
+	}
+	return otherMethod
+}
+
+// MetricsOptions configures NewMetrics. Zero values select the defaults.
+type MetricsOptions struct {
+	// Namespace prefixes every metric name. It defaults to "streamgo".
+	Namespace string
+
+	// LatencyBuckets are the request duration histogram bounds in seconds.
+	LatencyBuckets []float64
+
+	// SizeBuckets are the response size histogram bounds in bytes.
+	SizeBuckets []float64
+}
+
+// Metrics collects request, WebSocket and connection metrics of a Server
+// and exposes them in the Prometheus text format through MetricsPath.
+// Requests are labelled with the route pattern rather than the URL, and methods outside
+// the HTTPMethod constants are labelled OTHER, to keep cardinality bounded.
+type Metrics struct {
+	namespace      string
+	latencyBuckets []float64
+	sizeBuckets    []float64
+
+	mu       sync.Mutex
+	requests map[requestSeries]uint64
+	latency  map[routeSeries]*histogram
+	sizes    map[routeSeries]*histogram
+	inFlight map[string]int64
+
+	webSocketsOpen  int64
+	webSocketsTotal uint64
+
+	connStates map[net.Conn]http.ConnState
+	conns      map[connSeries]int64
+	connsTotal map[string]uint64
+}
+
+type requestSeries struct {
+	route, method string
+	status        int
+}
+
+type routeSeries struct {
+	route, method string
+}
+
+type connSeries struct {
+	listener string
+	state    http.ConnState
+}
+
+// histogram counts observations per bucket; counts has one extra slot for +Inf.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(bounds []float64, v float64) {
+	i := sort.SearchFloat64s(bounds, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// NewMetrics creates an empty metrics collector.
+func NewMetrics(opts MetricsOptions) *Metrics {
+	m := &Metrics{
+		namespace:      opts.Namespace,
+		latencyBuckets: sortedBuckets(opts.LatencyBuckets, DefaultLatencyBuckets),
+		sizeBuckets:    sortedBuckets(opts.SizeBuckets, DefaultSizeBuckets),
+		requests:       map[requestSeries]uint64{},
+		latency:        map[routeSeries]*histogram{},
+		sizes:          map[routeSeries]*histogram{},
+		inFlight:       map[string]int64{},
+		connStates:     map[net.Conn]http.ConnState{},
+		conns:          map[connSeries]int64{},
+		connsTotal:     map[string]uint64{},
+	}
+	if m.namespace == "" {
+		m.namespace = "streamgo"
+	}
+	return m
+}
+
+func sortedBuckets(buckets, defaults []float64) []float64 {
+	if len(buckets) == 0 {
+		buckets = defaults
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return buckets
+}
+
+// begin records the start of a request to route.
+func (m *Metrics) begin(route string) {
+	m.mu.Lock()
+	m.inFlight[route]++
+	m.mu.Unlock()
+}
+
+// end records a finished request to route.
+func (m *Metrics) end(route string, request *HTTPRequest, response *HTTPResponse) {
+	rw := response.recorder()
+	duration := time.Since(rw.start).Seconds()
+	status := rw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inFlight[route]--
+	method := methodLabel(request.HTTP.Method)
+	m.requests[requestSeries{route: route, method: method, status: status}]++
+
+	series := routeSeries{route: route, method: method}
+	m.histogram(m.latency, series, m.latencyBuckets).observe(m.latencyBuckets, duration)
+	m.histogram(m.sizes, series, m.sizeBuckets).observe(m.sizeBuckets, float64(rw.written))
+}
+
+func (m *Metrics) histogram(list map[routeSeries]*histogram, series routeSeries, bounds []float64) *histogram {
+	h, ok := list[series]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(bounds)+1)}
+		list[series] = h
+	}
+	return h
+}
+
+func (m *Metrics) webSocketOpened() {
+	m.mu.Lock()
+	m.webSocketsOpen++
+	m.webSocketsTotal++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) webSocketClosed() {
+	m.mu.Lock()
+	m.webSocketsOpen--
+	m.mu.Unlock()
+}
+
+// connState returns an http.Server ConnState hook that counts the connections of listener
+// and then calls next, if any.
+func (m *Metrics) connState(listener string, next func(net.Conn, http.ConnState)) func(net.Conn, http.ConnState) {
+	return func(conn net.Conn, state http.ConnState) {
+		m.mu.Lock()
+		if prev, ok := m.connStates[conn]; ok {
+			m.conns[connSeries{listener, prev}]--
+		} else {
+			m.connsTotal[listener]++
+		}
+		if state == http.StateClosed || state == http.StateHijacked {
+			delete(m.connStates, conn)
+		} else {
+			m.connStates[conn] = state
+			m.conns[connSeries{listener, state}]++
+		}
+		m.mu.Unlock()
+
+		if next != nil {
+			next(conn, state)
+		}
+	}
+}
+
+// MetricsPath returns an endpoint named name that serves m in the Prometheus text format.
+// Mount it with BuildPaths like any other Path.
+func MetricsPath[Payload any](m *Metrics, name string) Path[Payload] {
+	return Path[Payload]{
+		Name: name,
+		Handlers: map[HTTPMethod]HandlerFunc[Payload]{
+			GET: func(request *HTTPRequest, response *HTTPResponse, payload Payload) {
+				response.Writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+				response.Status(http.StatusOK)
+				response.Write([]byte(m.Expose()))
+			},
+		},
+	}
+}
+
+// Expose returns the current metrics in the Prometheus text exposition format.
+func (m *Metrics) Expose() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	name := func(metric string) string { return m.namespace + "_" + metric }
+
+	requests := name("http_requests_total")
+	header(&b, requests, "counter", "Total HTTP requests by route, method and status.")
+	keys := make([]requestSeries, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, c := keys[i], keys[j]
+		if a.route != c.route {
+			return a.route < c.route
+		}
+		if a.method != c.method {
+			return a.method < c.method
+		}
+		return a.status < c.status
+	})
+	for _, k := range keys {
+		sample(&b, requests, labels("route", k.route, "method", k.method, "status", strconv.Itoa(k.status)), float64(m.requests[k]))
+	}
+
+	writeHistograms(&b, name("http_request_duration_seconds"), "HTTP request duration in seconds by route and method.", m.latency, m.latencyBuckets)
+	writeHistograms(&b, name("http_response_size_bytes"), "HTTP response body size in bytes by route and method.", m.sizes, m.sizeBuckets)
+
+	inFlight := name("http_requests_in_flight")
+	header(&b, inFlight, "gauge", "HTTP requests currently being served by route.")
+	for _, route := range sortedKeys(m.inFlight) {
+		sample(&b, inFlight, labels("route", route), float64(m.inFlight[route]))
+	}
+
+	webSockets := name("websocket_connections")
+	header(&b, webSockets, "gauge", "WebSocket connections currently open.")
+	sample(&b, webSockets, "", float64(m.webSocketsOpen))
+	webSocketsTotal := name("websocket_connections_total")
+	header(&b, webSocketsTotal, "counter", "Total WebSocket connections upgraded.")
+	sample(&b, webSocketsTotal, "", float64(m.webSocketsTotal))
+
+	conns := name("connections")
+	header(&b, conns, "gauge", "Client connections by listener and state.")
+	connKeys := make([]connSeries, 0, len(m.conns))
+	for k := range m.conns {
+		connKeys = append(connKeys, k)
+	}
+	sort.Slice(connKeys, func(i, j int) bool {
+		if connKeys[i].listener != connKeys[j].listener {
+			return connKeys[i].listener < connKeys[j].listener
+		}
+		return connKeys[i].state < connKeys[j].state
+	})
+	for _, k := range connKeys {
+		sample(&b, conns, labels("listener", k.listener, "state", k.state.String()), float64(m.conns[k]))
+	}
+	connsTotal := name("connections_total")
+	header(&b, connsTotal, "counter", "Total client connections accepted by listener.")
+	for _, listener := range sortedKeys(m.connsTotal) {
+		sample(&b, connsTotal, labels("listener", listener), float64(m.connsTotal[listener]))
+	}
+
+	return b.String()
+}
+
+func writeHistograms(b *strings.Builder, name, help string, list map[routeSeries]*histogram, bounds []float64) {
+	header(b, name, "histogram", help)
+
+	keys := make([]routeSeries, 0, len(list))
+	for k := range list {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	for _, k := range keys {
+		h := list[k]
+		var cumulative uint64
+		for i, bound := range bounds {
+			cumulative += h.counts[i]
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			sample(b, name+"_bucket", labels("route", k.route, "method", k.method, "le", le), float64(cumulative))
+		}
+		sample(b, name+"_bucket", labels("route", k.route, "method", k.method, "le", "+Inf"), float64(h.count))
+		sample(b, name+"_sum", labels("route", k.route, "method", k.method), h.sum)
+		sample(b, name+"_count", labels("route", k.route, "method", k.method), float64(h.count))
+	}
+}
+
+func header(b *strings.Builder, name, kind, help string) {
+	b.WriteString("# HELP " + name + " " + help + "\n")
+	b.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+func sample(b *strings.Builder, name, labels string, value float64) {
+	b.WriteString(name)
+	b.WriteString(labels)
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	b.WriteByte('\n')
+}
+
+// labels formats name and value pairs as a Prometheus label set.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
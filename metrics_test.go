@@ -0,0 +1,55 @@
+package streamgo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetricsLabels(t *testing.T) {
+	m := NewMetrics(MetricsOptions{})
+	s := newTestServer(t, answerOK, []Path[string]{{Name: "/users/:id:"}})
+	s.Metrics = m
+
+	for _, req := range []struct{ method, url string }{
+		{"GET", "/users/1"},
+		{"GET", "/users/2"},
+		{"X", "/users/1"},
+		{"XY", "/users/1"},
+		{"BREW", "/nowhere"},
+		{"GET", "/nowhere"},
+	} {
+		serve(s, req.method, req.url)
+	}
+
+	out := m.Expose()
+	for _, want := range []string{
+		`streamgo_http_requests_total{route="/users/:id:/",method="GET",status="200"} 2`,
+		`streamgo_http_requests_total{route="/users/:id:/",method="OTHER",status="405"} 2`,
+		`streamgo_http_requests_total{route="unmatched",method="GET",status="404"} 1`,
+		`streamgo_http_requests_total{route="unmatched",method="OTHER",status="404"} 1`,
+		`streamgo_http_request_duration_seconds_count{route="/users/:id:/",method="OTHER"} 2`,
+		`streamgo_http_requests_in_flight{route="/users/:id:/"} 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("exposition is missing %s\n%s", want, out)
+		}
+	}
+	for _, raw := range []string{`method="X"`, `method="XY"`, `method="BREW"`} {
+		if strings.Contains(out, raw) {
+			t.Errorf("exposition has series with %s", raw)
+		}
+	}
+}
+
+func TestMethodLabel(t *testing.T) {
+	for _, method := range []HTTPMethod{GET, POST, PUT, DELETE, PATCH, OPTIONS, HEAD, TRACE, CONNECT} {
+		if got := methodLabel(string(method)); got != string(method) {
+			t.Errorf("methodLabel(%q) = %q", method, got)
+		}
+	}
+	for _, method := range []string{"", "get", "PROPFIND", "X"} {
+		if got := methodLabel(method); got != otherMethod {
+			t.Errorf("methodLabel(%q) = %q, want %q", method, got, otherMethod)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package streamgo
+
+// Middleware wraps a handler with additional behaviour.
+// It may run code before and after calling next, or return without calling next
+// to short-circuit the request.
+type Middleware[Payload any] func(next HandlerFunc[Payload]) HandlerFunc[Payload]
+
+// Chain wraps handler with every middleware of the given groups.
+// Groups and the middleware inside them run in order: the first one is the outermost.
+func Chain[Payload any](handler HandlerFunc[Payload], groups ...[]Middleware[Payload]) HandlerFunc[Payload] {
+	for g := len(groups) - 1; g >= 0; g-- {
+		for i := len(groups[g]) - 1; i >= 0; i-- {
+			handler = groups[g][i](handler)
+		}
+	}
+	return handler
+}
+
+// inheritMiddlewares returns the middleware of a parent followed by own,
+// without sharing the backing array of either slice.
+func inheritMiddlewares[Payload any](parent, own []Middleware[Payload]) []Middleware[Payload] {
+	if len(parent) == 0 && len(own) == 0 {
+		return nil
+	}
+	list := make([]Middleware[Payload], 0, len(parent)+len(own))
+	list = append(list, parent...)
+	return append(list, own...)
+}
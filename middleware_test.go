@@ -0,0 +1,78 @@
+package streamgo
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// trace returns a middleware appending name to the X-Trace header, and counts how often
+// it is composed into a chain in built.
+func trace(name string, built *int) Middleware[string] {
+	return func(next HandlerFunc[string]) HandlerFunc[string] {
+		*built++
+		return func(request *HTTPRequest, response *HTTPResponse, payload string) {
+			response.Writer.Header().Add("X-Trace", name)
+			next(request, response, payload)
+		}
+	}
+}
+
+func TestMiddlewareScopes(t *testing.T) {
+	var built int
+	s := newTestServer(t, answerOK, []Path[string]{
+		{Name: "/api", Middlewares: []Middleware[string]{trace("api", &built)}, Include: []Path[string]{
+			{Name: "/users", Middlewares: []Middleware[string]{trace("users", &built)}},
+			{Name: "/posts"},
+		}},
+		{Name: "/public"},
+	}, func(s *Server[string]) {
+		s.Middlewares = []Middleware[string]{trace("server", &built)}
+	})
+
+	tests := []struct {
+		method, url string
+		want        string
+	}{
+		{"GET", "/api/users", "server,api,users"},
+		{"GET", "/api/posts", "server,api"},
+		{"GET", "/api", "server,api"},
+		{"GET", "/public", "server"},
+		{"POST", "/api/users", "server,api,users"},
+		{"GET", "/nowhere", "server"},
+	}
+	for _, tt := range tests {
+		w := do(s, httptest.NewRequest(tt.method, tt.url, nil))
+		if got := strings.Join(w.Header().Values("X-Trace"), ","); got != tt.want {
+			t.Errorf("%s %s ran %q, want %q", tt.method, tt.url, got, tt.want)
+		}
+	}
+
+	// The chains are composed by Compile, not per request.
+	before := built
+	for range 10 {
+		serve(s, "GET", "/api/users")
+		serve(s, "GET", "/nowhere")
+	}
+	if built != before {
+		t.Errorf("serving composed %d more middleware, want none", built-before)
+	}
+}
+
+func TestMiddlewareShortCircuitKeepsCORS(t *testing.T) {
+	deny := func(next HandlerFunc[string]) HandlerFunc[string] {
+		return func(request *HTTPRequest, response *HTTPResponse, payload string) {
+			response.Status(401)
+		}
+	}
+	s := newTestServer(t, answerOK, []Path[string]{
+		{Name: "/private", Middlewares: []Middleware[string]{deny}, CORS: &CORSPolicy{AllowedOrigins: []string{"https://app.example"}}},
+	})
+
+	r := httptest.NewRequest("GET", "/private", nil)
+	r.Header.Set("Origin", "https://app.example")
+	w := do(s, r)
+	if w.Code != 401 || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example" {
+		t.Errorf("rejected request = %d %v, want 401 readable by the origin", w.Code, w.Header())
+	}
+}
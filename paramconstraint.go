@@ -0,0 +1,119 @@
+package streamgo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ParamConstraint reports whether a captured parameter value is acceptable.
+// Constraints are referenced from route names as `:name<constraint>:` or `::name<constraint>::`.
+type ParamConstraint func(value string) bool
+
+var ErrUnknownConstraint = errors.New("unknown parameter constraint")
+
+// DefaultParamConstraints are registered on every RegexOptions created by NewRegexOptions.
+// Besides these, `re:<expr>` matches the whole value against a regular expression.
+var DefaultParamConstraints = map[string]ParamConstraint{
+	"int":  isIntParam,
+	"slug": isSlugParam,
+	"uuid": isUUIDParam,
+	"date": isDateParam,
+}
+
+// RegisterConstraint makes c available to route names under the given name.
+// It must be called before BuildPaths.
+func (ro *RegexOptions) RegisterConstraint(name string, c ParamConstraint) {
+	if ro.constraints == nil {
+		ro.constraints = map[string]ParamConstraint{}
+	}
+	ro.constraints[name] = c
+}
+
+// constraint resolves a constraint referenced from a route name.
+func (ro *RegexOptions) constraint(name string) (ParamConstraint, error) {
+	if expr, ok := strings.CutPrefix(name, "re:"); ok {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrUnknownConstraint, name, err)
+		}
+		return re.MatchString, nil
+	}
+
+	if c, ok := ro.constraints[name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownConstraint, name)
+}
+
+// splitParam separates a matched parameter into its name and optional constraint.
+// Colons are stripped from the name only, so `re:` expressions keep theirs.
+func splitParam(raw string) (string, string) {
+	lt := strings.IndexByte(raw, '<')
+	gt := strings.LastIndexByte(raw, '>')
+	if lt < 0 || gt < lt {
+		return strings.ReplaceAll(raw, ":", ""), ""
+	}
+	return strings.ReplaceAll(raw[:lt], ":", ""), raw[lt+1 : gt]
+}
+
+func isIntParam(s string) bool {
+	if len(s) > 1 && s[0] == '-' {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isSlugParam(s string) bool {
+	if s == "" || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		case c == '-' && s[i-1] != '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isUUIDParam(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isDateParam(s string) bool {
+	if len(s) != len(time.DateOnly) {
+		return false
+	}
+	_, err := time.Parse(time.DateOnly, s)
+	return err == nil
+}
@@ -0,0 +1,114 @@
+package streamgo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDefaultParamConstraints(t *testing.T) {
+	tests := []struct {
+		constraint string
+		value      string
+		want       bool
+	}{
+		{"int", "42", true},
+		{"int", "-42", true},
+		{"int", "-", false},
+		{"int", "4.2", false},
+		{"int", "", false},
+		{"slug", "hello-world-2", true},
+		{"slug", "Hello", false},
+		{"slug", "-hello", false},
+		{"slug", "hello-", false},
+		{"slug", "hello--world", false},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", true},
+		{"uuid", "123E4567-E89B-12D3-A456-426614174000", true},
+		{"uuid", "123e4567e89b12d3a456426614174000", false},
+		{"uuid", "123e4567-e89b-12d3-a456-42661417400g", false},
+		{"date", "2024-02-29", true},
+		{"date", "2023-02-29", false},
+		{"date", "2024-2-29", false},
+	}
+	for _, tt := range tests {
+		if got := DefaultParamConstraints[tt.constraint](tt.value); got != tt.want {
+			t.Errorf("%s(%q) = %v, want %v", tt.constraint, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSplitParam(t *testing.T) {
+	tests := []struct {
+		raw, name, constraint string
+	}{
+		{":id:", "id", ""},
+		{"::page::", "page", ""},
+		{":id<int>:", "id", "int"},
+		{"::page<int>::", "page", "int"},
+		{":code<re:[a-z]{2}:[0-9]+>:", "code", "re:[a-z]{2}:[0-9]+"},
+	}
+	for _, tt := range tests {
+		if name, constraint := splitParam(tt.raw); name != tt.name || constraint != tt.constraint {
+			t.Errorf("splitParam(%q) = %q, %q, want %q, %q", tt.raw, name, constraint, tt.name, tt.constraint)
+		}
+	}
+}
+
+func TestConstrainedRoutes(t *testing.T) {
+	s := newRouteServer(t, false,
+		"/n/:id<int>:",
+		"/n/:name:",
+		"/p/:slug<slug>:",
+		"/o/:id<uuid>:",
+		"/d/:day<date>:",
+		"/c/:code<re:[a-z]{2}-[0-9]+>:",
+		"/l/::page<int>::",
+	)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		// A constrained parameter is tried before an unconstrained sibling.
+		{"/n/42", "/n/:id<int>:|id=42"},
+		{"/n/abc", "/n/:name:|name=abc"},
+		{"/p/hello-world", "/p/:slug<slug>:|slug=hello-world"},
+		{"/p/Hello", "404"},
+		{"/o/123e4567-e89b-12d3-a456-426614174000", "/o/:id<uuid>:|id=123e4567-e89b-12d3-a456-426614174000"},
+		{"/o/123", "404"},
+		{"/d/2024-02-29", "/d/:day<date>:|day=2024-02-29"},
+		{"/d/2024-02-30", "404"},
+		{"/c/ab-12", "/c/:code<re:[a-z]{2}-[0-9]+>:|code=ab-12"},
+		// Regular expressions must match the whole value.
+		{"/c/ab-12x", "404"},
+		{"/l", "/l/::page<int>::|"},
+		{"/l/3", "/l/::page<int>::|page=3"},
+		{"/l/x", "404"},
+	}
+	for _, tt := range tests {
+		if got := serve(s, "GET", tt.url); got != tt.want {
+			t.Errorf("GET %s = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterConstraint(t *testing.T) {
+	even := func(value string) bool {
+		return isIntParam(value) && (value[len(value)-1]-'0')%2 == 0
+	}
+	s := newTestServer(t, answerOK, []Path[string]{{Name: "/e/:n<even>:"}}, func(s *Server[string]) {
+		s.RegexOptions.RegisterConstraint("even", even)
+	})
+
+	if got := serve(s, "GET", "/e/4"); got != "ok" {
+		t.Errorf("GET /e/4 = %q, want ok", got)
+	}
+	if got := serve(s, "GET", "/e/3"); got != "404" {
+		t.Errorf("GET /e/3 = %q, want 404", got)
+	}
+
+	// Constraints registered on one server are not visible to others.
+	other := NewServer[string](NewRegexOptions(1))
+	if err := other.BuildPaths([]Path[string]{{Name: "/e/:n<even>:"}}, ""); !errors.Is(err, ErrUnknownConstraint) {
+		t.Errorf("BuildPaths on another server = %v, want %v", err, ErrUnknownConstraint)
+	}
+}
@@ -2,6 +2,8 @@ package streamgo
 
 import (
 	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/gorilla/websocket"
 )
@@ -27,12 +29,51 @@ type Path[Payload any] struct {
 	// It must be configured if an HTTP connection is required.
 	HTTP HTTP
 
+	// Handlers optionally maps HTTP methods to dedicated handlers for this endpoint.
+	// Its keys are allowed in addition to HTTP.Methods, and Server.HTTPHandler serves
+	// the methods allowed through HTTP.Methods that have no handler of their own.
+	Handlers map[HTTPMethod]HandlerFunc[Payload]
+
+	// Middlewares wrap every handler dispatched for this endpoint, including 405 and WebSocket handlers.
+	// Endpoints listed in Include inherit them and run them before their own.
+	Middlewares []Middleware[Payload]
+
+	// middlewares is the resolved chain of inherited and own middleware, set by BuildPaths.
+	middlewares []Middleware[Payload]
+
+	// handle is the route handler behind Server.Middlewares and middlewares, composed by Compile.
+	handle HandlerFunc[Payload]
+
+	// CORS configures cross-origin requests for this endpoint and its Include children.
+	// When nil, the policy of the parent or Server.CORS applies.
+	CORS *CORSPolicy
+
+	// cors is the resolved CORS configuration, set by BuildPaths.
+	cors *corsConfig
+
+	// RateLimit answers requests over the limit with 429 Too Many Requests.
+	// Endpoints in Include inherit it, sharing its budget, unless they set their own.
+	RateLimit *RateLimit
+
+	// ConcurrencyLimit caps how many requests to the endpoint run at once.
+	// Endpoints in Include inherit it, sharing its slots, unless they set their own.
+	ConcurrencyLimit *ConcurrencyLimit
+
 	// WebSocket holds the configuration details for a WebSocket connection.
 	// This must be set if a WebSocket connection is required.
 	WebSocket WS
 
 	// mappedParams is a map of parameter indices to their corresponding names.
 	mappedParams map[int]string
+
+	// paramNames lists the parameter names in the order the route tree captures them.
+	paramNames []string
+
+	// allow is the precomputed Allow header value, set by BuildPaths.
+	allow string
+
+	// pattern is the full route name including the prefix of its parents, set by BuildPaths.
+	pattern string
 }
 
 // HTTP represents the HTTP configuration for an endpoint.
@@ -41,6 +82,14 @@ type HTTP struct {
 	// Methods is a map that defines the allowed HTTP methods for the endpoint.
 	// The key represents the HTTP method, and the value indicates whether it is allowed.
 	Methods map[HTTPMethod]bool
+
+	// DisableAutoHead stops HEAD requests from being served by the GET handler
+	// when HEAD is not allowed explicitly. net/http discards the body of HEAD responses.
+	DisableAutoHead bool
+
+	// DisableAutoOptions stops OPTIONS requests from being answered automatically
+	// with an Allow header when OPTIONS is not allowed explicitly.
+	DisableAutoOptions bool
 }
 
 // WS represents the WebSocket configuration for an endpoint.
@@ -51,16 +100,77 @@ type WS struct {
 }
 
 // NormalizeMethods ensures that the HTTP.Methods map is initialized.
+// The methods of Handlers are added to it, in a copy so the caller's map is left untouched.
 // If no methods are defined, it defaults to allowing only the GET method.
 func (p *Path[Payload]) NormalizeMethods() {
+	if len(p.Handlers) > 0 {
+		methods := make(map[HTTPMethod]bool, len(p.HTTP.Methods)+len(p.Handlers))
+		for method, allowed := range p.HTTP.Methods {
+			methods[method] = allowed
+		}
+		for method := range p.Handlers {
+			methods[method] = true
+		}
+		p.HTTP.Methods = methods
+		return
+	}
 	if p.HTTP.Methods != nil {
 		return
 	}
 	p.HTTP.Methods = map[HTTPMethod]bool{GET: true}
 }
 
+// Handler returns the handler registered for method in Handlers.
+// It returns nil when the endpoint has no dedicated handler for the method.
+func (p *Path[Payload]) Handler(method string) HandlerFunc[Payload] {
+	return p.Handlers[HTTPMethod(method)]
+}
+
 // IsMethodAllowed checks whether a given HTTP method is allowed for the endpoint.
 // It returns true if the method is allowed, otherwise false.
 func (p *Path[Payload]) IsMethodAllowed(method string) bool {
 	return p.HTTP.Methods[HTTPMethod(method)]
 }
+
+// AllowsAutoHead reports whether a HEAD request is served by the GET handler.
+func (p *Path[Payload]) AllowsAutoHead() bool {
+	return !p.HTTP.DisableAutoHead && p.HTTP.Methods[GET] && !p.HTTP.Methods[HEAD]
+}
+
+// AllowsAutoOptions reports whether an OPTIONS request is answered automatically.
+func (p *Path[Payload]) AllowsAutoOptions() bool {
+	return !p.HTTP.DisableAutoOptions && !p.HTTP.Methods[OPTIONS]
+}
+
+// AllowedMethods returns the sorted methods the endpoint answers,
+// including the automatic HEAD and OPTIONS handling.
+func (p *Path[Payload]) AllowedMethods() []string {
+	methods := make([]string, 0, len(p.HTTP.Methods)+2)
+	for method, allowed := range p.HTTP.Methods {
+		if allowed {
+			methods = append(methods, string(method))
+		}
+	}
+	if p.AllowsAutoHead() {
+		methods = append(methods, string(HEAD))
+	}
+	if p.AllowsAutoOptions() {
+		methods = append(methods, string(OPTIONS))
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Pattern returns the full route name the endpoint was registered under, such as "/users/:id:".
+// Unlike the request URL it has a bounded number of values, which suits logs and metrics.
+func (p *Path[Payload]) Pattern() string {
+	return p.pattern
+}
+
+// Allow returns the value of the Allow header for the endpoint.
+func (p *Path[Payload]) Allow() string {
+	if p.allow != "" {
+		return p.allow
+	}
+	return strings.Join(p.AllowedMethods(), ", ")
+}
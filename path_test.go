@@ -0,0 +1,60 @@
+package streamgo
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPathHandlers(t *testing.T) {
+	handler := func(name string) HandlerFunc[string] {
+		return func(request *HTTPRequest, response *HTTPResponse, payload string) {
+			response.HTML(name)
+		}
+	}
+	methods := map[HTTPMethod]bool{PUT: true}
+	s := newTestServer(t, handler("server"), []Path[string]{
+		{Name: "/only", Handlers: map[HTTPMethod]HandlerFunc[string]{GET: handler("get"), POST: handler("post")}},
+		{Name: "/mixed", HTTP: HTTP{Methods: methods}, Handlers: map[HTTPMethod]HandlerFunc[string]{DELETE: handler("delete")}},
+		{Name: "/plain", HTTP: HTTP{Methods: map[HTTPMethod]bool{GET: true, PATCH: true}}},
+	})
+
+	tests := []struct {
+		method, url string
+		want        string
+		allow       string
+	}{
+		{"GET", "/only", "get", ""},
+		{"POST", "/only", "post", ""},
+		{"HEAD", "/only", "", ""},
+		{"PUT", "/only", "405", "GET, HEAD, OPTIONS, POST"},
+		// Methods allowed through HTTP.Methods without a handler fall back to Server.HTTPHandler.
+		{"PUT", "/mixed", "server", ""},
+		{"DELETE", "/mixed", "delete", ""},
+		{"GET", "/mixed", "405", "DELETE, OPTIONS, PUT"},
+		{"PATCH", "/plain", "server", ""},
+	}
+	for _, tt := range tests {
+		w := do(s, httptest.NewRequest(tt.method, tt.url, nil))
+		if w.Body.String() != tt.want || w.Header().Get("Allow") != tt.allow {
+			t.Errorf("%s %s = %q, Allow %q, want %q, Allow %q", tt.method, tt.url, w.Body.String(), w.Header().Get("Allow"), tt.want, tt.allow)
+		}
+	}
+
+	if len(methods) != 1 {
+		t.Errorf("HTTP.Methods of the caller was modified: %v", methods)
+	}
+}
+
+func TestNormalizeMethods(t *testing.T) {
+	p := Path[string]{}
+	p.NormalizeMethods()
+	if len(p.HTTP.Methods) != 1 || !p.HTTP.Methods[GET] {
+		t.Errorf("default methods = %v, want GET", p.HTTP.Methods)
+	}
+
+	p = Path[string]{Handlers: map[HTTPMethod]HandlerFunc[string]{POST: nil}}
+	p.NormalizeMethods()
+	if len(p.HTTP.Methods) != 1 || !p.HTTP.Methods[POST] {
+		t.Errorf("methods with handlers = %v, want only POST", p.HTTP.Methods)
+	}
+}
@@ -0,0 +1,238 @@
+package streamgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrProxyHeader = errors.New("invalid PROXY protocol header")
+
+// proxyV2Signature starts every PROXY protocol v2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ProxyProtocolOptions enables HAProxy PROXY protocol v1 and v2 on a listener.
+// Connections from trusted sources may start with a PROXY header, whose client address
+// then becomes the RemoteAddr of the request. Connections from other sources are served
+// unchanged, so a client cannot spoof its address with a header of its own.
+type ProxyProtocolOptions struct {
+	// TrustedSources lists the IPs or CIDRs of the load balancers allowed to send headers.
+	TrustedSources []string
+
+	// HeaderTimeout limits how long reading the header may take. It defaults to 5 seconds.
+	HeaderTimeout time.Duration
+}
+
+// parseTrustedNetworks parses IPs and CIDRs into networks.
+// A single IP becomes a /32 or /128 network depending on its family.
+func parseTrustedNetworks(list []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, v := range list {
+		if _, network, err := net.ParseCIDR(v); err == nil {
+			networks = append(networks, network)
+			continue
+		}
+
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", v)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			networks = append(networks, &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)})
+		} else {
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+		}
+	}
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyListener wraps accepted connections with PROXY protocol parsing.
+type proxyListener struct {
+	net.Listener
+	trusted []*net.IPNet
+	timeout time.Duration
+}
+
+func newProxyListener(listener net.Listener, opts *ProxyProtocolOptions) (net.Listener, error) {
+	trusted, err := parseTrustedNetworks(opts.TrustedSources)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := opts.HeaderTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &proxyListener{Listener: listener, trusted: trusted, timeout: timeout}, nil
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if tcp, ok := conn.RemoteAddr().(*net.TCPAddr); !ok || !containsIP(l.trusted, tcp.IP) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, timeout: l.timeout}, nil
+}
+
+// proxyConn reads the PROXY header lazily, on the first Read or RemoteAddr call,
+// so a slow client never blocks the accept loop.
+type proxyConn struct {
+	net.Conn
+	timeout time.Duration
+
+	once   sync.Once
+	reader *bufio.Reader
+	remote net.Addr
+	err    error
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.reader = bufio.NewReader(c.Conn)
+		c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		c.remote, c.err = readProxyHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+		if c.err != nil {
+			c.Conn.Close()
+		}
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address conveyed by the PROXY header,
+// or the address of the peer when there was none.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader consumes a v1 or v2 header if the stream starts with one.
+// It returns a nil address when there is no header or it carries no client address.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	if startsWith(r, proxyV2Signature) {
+		return readProxyHeaderV2(r)
+	}
+	if startsWith(r, []byte("PROXY ")) {
+		return readProxyHeaderV1(r)
+	}
+	return nil, nil
+}
+
+// startsWith reports whether the stream starts with prefix without consuming it.
+// It only waits for more data while the bytes received so far match, so a client
+// without a header is never held up by a short first write.
+func startsWith(r *bufio.Reader, prefix []byte) bool {
+	for n := 1; n <= len(prefix); n++ {
+		b, err := r.Peek(n)
+		if err != nil || !bytes.Equal(b, prefix[:n]) {
+			return false
+		}
+	}
+	return true
+}
+
+// readProxyHeaderV1 parses "PROXY TCP4 <src> <dst> <sport> <dport>\r\n".
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrProxyHeader, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+
+	s, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, fmt.Errorf("%w: v1 header too long", ErrProxyHeader)
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: %q", ErrProxyHeader, s)
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrProxyHeader, s)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyHeaderV2 parses the binary header, skipping any TLVs.
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	var head [16]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProxyHeader, err)
+	}
+
+	if head[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrProxyHeader, head[12]>>4)
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProxyHeader, err)
+	}
+
+	// LOCAL connections, such as health checks, keep the peer address.
+	if head[12]&0x0F == 0 {
+		return nil, nil
+	}
+	if head[12]&0x0F != 1 {
+		return nil, fmt.Errorf("%w: unsupported command %d", ErrProxyHeader, head[12]&0x0F)
+	}
+
+	switch head[13] >> 4 {
+	case 1: // AF_INET
+		if len(body) < 12 {
+			return nil, fmt.Errorf("%w: short IPv4 address block", ErrProxyHeader)
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case 2: // AF_INET6
+		if len(body) < 36 {
+			return nil, fmt.Errorf("%w: short IPv6 address block", ErrProxyHeader)
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,236 @@
+package streamgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyV2 builds a v2 header with the given command, family byte and address block.
+func proxyV2(command, family byte, block []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(block)))
+	return append(header, block...)
+}
+
+// ipv4Block is 192.0.2.1:51000 -> 198.51.100.1:443.
+func ipv4Block() []byte {
+	block := append(net.ParseIP("192.0.2.1").To4(), net.ParseIP("198.51.100.1").To4()...)
+	block = binary.BigEndian.AppendUint16(block, 51000)
+	return binary.BigEndian.AppendUint16(block, 443)
+}
+
+// ipv6Block is [2001:db8::1]:51000 -> [2001:db8::2]:443.
+func ipv6Block() []byte {
+	block := append(net.ParseIP("2001:db8::1").To16(), net.ParseIP("2001:db8::2").To16()...)
+	block = binary.BigEndian.AppendUint16(block, 51000)
+	return binary.BigEndian.AppendUint16(block, 443)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	const payload = "GET / HTTP/1.1\r\n"
+
+	tests := []struct {
+		name    string
+		header  []byte
+		want    string
+		wantErr bool
+	}{
+		{"no header", nil, "", false},
+		{"v1 tcp4", []byte("PROXY TCP4 192.0.2.1 198.51.100.1 51000 443\r\n"), "192.0.2.1:51000", false},
+		{"v1 tcp6", []byte("PROXY TCP6 2001:db8::1 2001:db8::2 51000 443\r\n"), "[2001:db8::1]:51000", false},
+		{"v1 unknown", []byte("PROXY UNKNOWN\r\n"), "", false},
+		{"v1 unknown with addresses", []byte("PROXY UNKNOWN 192.0.2.1 198.51.100.1 51000 443\r\n"), "", false},
+		{"v1 at 107 bytes", []byte("PROXY UNKNOWN " + strings.Repeat("x", 107-16) + "\r\n"), "", false},
+		{"v1 over 107 bytes", []byte("PROXY UNKNOWN " + strings.Repeat("x", 108-16) + "\r\n"), "", true},
+		{"v1 missing CRLF", []byte("PROXY TCP4 192.0.2.1 198.51.100.1 51000 443\n"), "", true},
+		{"v1 bad protocol", []byte("PROXY UDP4 192.0.2.1 198.51.100.1 51000 443\r\n"), "", true},
+		{"v1 bad address", []byte("PROXY TCP4 192.0.2.x 198.51.100.1 51000 443\r\n"), "", true},
+		{"v1 bad port", []byte("PROXY TCP4 192.0.2.1 198.51.100.1 70000 443\r\n"), "", true},
+		{"v1 missing fields", []byte("PROXY TCP4 192.0.2.1\r\n"), "", true},
+		{"v2 proxy ipv4", proxyV2(1, 0x11, ipv4Block()), "192.0.2.1:51000", false},
+		{"v2 proxy ipv6", proxyV2(1, 0x21, ipv6Block()), "[2001:db8::1]:51000", false},
+		{"v2 proxy with TLVs", proxyV2(1, 0x11, append(ipv4Block(), 0x04, 0x00, 0x01, 0xff)), "192.0.2.1:51000", false},
+		{"v2 local", proxyV2(0, 0x11, ipv4Block()), "", false},
+		{"v2 local without addresses", proxyV2(0, 0x00, nil), "", false},
+		{"v2 unspecified family", proxyV2(1, 0x00, nil), "", false},
+		{"v2 truncated ipv4 block", proxyV2(1, 0x11, ipv4Block()[:8]), "", true},
+		{"v2 truncated ipv6 block", proxyV2(1, 0x21, ipv6Block()[:20]), "", true},
+		{"v2 unknown command", proxyV2(2, 0x11, ipv4Block()), "", true},
+		{"v2 wrong version", append(append([]byte{}, proxyV2Signature...), 0x11, 0x11, 0, 0), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(bytes.NewReader(append(append([]byte{}, tt.header...), payload...)))
+			addr, err := readProxyHeader(r)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrProxyHeader) {
+					t.Fatalf("readProxyHeader = %v, %v, want ErrProxyHeader", addr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readProxyHeader: %v", err)
+			}
+
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tt.want {
+				t.Errorf("address = %q, want %q", got, tt.want)
+			}
+
+			// The header is consumed and the request that follows is left untouched.
+			if rest, _ := io.ReadAll(r); string(rest) != payload {
+				t.Errorf("remaining data = %q, want %q", rest, payload)
+			}
+		})
+	}
+}
+
+func TestReadProxyHeaderV2TruncatedStream(t *testing.T) {
+	header := proxyV2(1, 0x11, ipv4Block())
+	for _, n := range []int{len(proxyV2Signature) + 2, len(header) - 1} {
+		r := bufio.NewReader(bytes.NewReader(header[:n]))
+		if _, err := readProxyHeader(r); !errors.Is(err, ErrProxyHeader) {
+			t.Errorf("readProxyHeader of %d bytes = %v, want ErrProxyHeader", n, err)
+		}
+	}
+}
+
+func TestReadProxyHeaderShortFirstWrite(t *testing.T) {
+	// Clients without a header may send less than a signature before waiting for an answer.
+	for _, first := range []string{"G", "PU", "\r\nG"} {
+		server, client := net.Pipe()
+		go io.WriteString(client, first)
+
+		done := make(chan error, 1)
+		r := bufio.NewReader(server)
+		go func() {
+			_, err := readProxyHeader(r)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("readProxyHeader after %q = %v", first, err)
+			}
+			if got, _ := r.Peek(len(first)); string(got) != first {
+				t.Errorf("buffered %q, want %q", got, first)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("readProxyHeader blocked after %q", first)
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+// acceptProxied writes data from a loopback client to a proxy listener trusting sources,
+// and returns the accepted connection's RemoteAddr and what it read.
+func acceptProxied(t *testing.T, sources []string, data string) (net.Addr, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	pl, err := newProxyListener(ln, &ProxyProtocolOptions{TrustedSources: sources, HeaderTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := io.WriteString(client, data); err != nil {
+		t.Fatal(err)
+	}
+	client.(*net.TCPConn).CloseWrite()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	read, _ := io.ReadAll(conn)
+	return conn.RemoteAddr(), string(read)
+}
+
+func TestProxyListenerTrustedSource(t *testing.T) {
+	remote, read := acceptProxied(t, []string{"127.0.0.1"}, "PROXY TCP4 192.0.2.1 198.51.100.1 51000 443\r\nhello")
+
+	if remote.String() != "192.0.2.1:51000" {
+		t.Errorf("RemoteAddr = %v, want 192.0.2.1:51000", remote)
+	}
+	if read != "hello" {
+		t.Errorf("read %q, want %q", read, "hello")
+	}
+}
+
+func TestProxyListenerUntrustedSource(t *testing.T) {
+	const data = "PROXY TCP4 192.0.2.1 198.51.100.1 51000 443\r\nhello"
+	remote, read := acceptProxied(t, []string{"10.0.0.0/8", "2001:db8::/32"}, data)
+
+	// The header of an untrusted peer is not interpreted: the address stays the peer's
+	// and the bytes reach the application as sent.
+	if host := hostOf(remote.String()); host != "127.0.0.1" {
+		t.Errorf("RemoteAddr = %v, want the loopback peer", remote)
+	}
+	if read != data {
+		t.Errorf("read %q, want %q", read, data)
+	}
+}
+
+func TestProxyListenerInvalidHeaderClosesConnection(t *testing.T) {
+	remote, read := acceptProxied(t, []string{"127.0.0.0/8"}, "PROXY TCP4 nonsense\r\nhello")
+
+	if host := hostOf(remote.String()); host != "127.0.0.1" {
+		t.Errorf("RemoteAddr = %v, want the loopback peer", remote)
+	}
+	if read != "" {
+		t.Errorf("read %q from a connection with an invalid header", read)
+	}
+}
+
+func TestParseTrustedNetworks(t *testing.T) {
+	networks, err := parseTrustedNetworks([]string{"192.0.2.1", "2001:db8::1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"192.0.2.1", true},
+		{"192.0.2.2", false},
+		{"2001:db8::1", true},
+		{"2001:db8::2", false},
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+	}
+	for _, tt := range tests {
+		if got := containsIP(networks, net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("containsIP(%v) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	if _, err := parseTrustedNetworks([]string{"nope"}); err == nil {
+		t.Error("parseTrustedNetworks accepted an invalid entry")
+	}
+}
@@ -0,0 +1,335 @@
+package streamgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrInvalidRateLimit = errors.New("rate limit needs a positive limit and window")
+
+// RateLimitAlgorithm selects how a RateLimit counts requests.
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts of up to Limit requests and refills Limit tokens per Window.
+	TokenBucket RateLimitAlgorithm = iota
+
+	// SlidingWindow allows Limit requests in any Window, weighting the previous window
+	// by how much of it still overlaps.
+	SlidingWindow
+)
+
+// RateLimitKey returns the key a request is counted under.
+type RateLimitKey func(request *HTTPRequest) string
+
+// KeyByIP counts requests per client IP, as determined by Server.ClientIPResolver.
+func KeyByIP() RateLimitKey {
+	return func(request *HTTPRequest) string {
+		return request.ClientIP()
+	}
+}
+
+// KeyByHeader counts requests per value of the header name.
+func KeyByHeader(name string) RateLimitKey {
+	return func(request *HTTPRequest) string {
+		return request.Header(name)
+	}
+}
+
+// KeyByCookie counts requests per value of the cookie name.
+func KeyByCookie(name string) RateLimitKey {
+	return func(request *HTTPRequest) string {
+		if cookie, err := request.HTTP.Cookie(name); err == nil {
+			return cookie.Value
+		}
+		return ""
+	}
+}
+
+// RateLimit limits how often a client may call the endpoints it applies to.
+// Set it on Path.RateLimit; endpoints in Include inherit it and share its budget
+// unless they set a RateLimit of their own.
+type RateLimit struct {
+	// Name separates the counters of this limit from others in a shared Store.
+	// It defaults to a name unique to this RateLimit within the process.
+	Name string
+
+	Algorithm RateLimitAlgorithm
+
+	// Limit is the number of requests allowed per Window.
+	Limit int
+
+	// Window is the period Limit applies to.
+	Window time.Duration
+
+	// Key determines which client a request is counted for. It defaults to KeyByIP().
+	// Requests for which Key returns an empty string are counted by client IP.
+	Key RateLimitKey
+
+	// Store keeps the counters. It defaults to a MemoryRateLimitStore private to this limit.
+	// When the store fails, requests are let through and the error is logged.
+	Store RateLimitStore
+
+	// Logger receives store failures. It defaults to the Server.Logger of the server
+	// whose Path sets this limit, or to slog.Default() when installed through RateLimitMiddleware.
+	Logger *slog.Logger
+
+	once   sync.Once
+	prefix string
+	store  RateLimitStore
+}
+
+// RateLimitResult is the outcome of counting one request.
+type RateLimitResult struct {
+	// Allowed reports whether the request is within the limit.
+	Allowed bool
+
+	Limit     int
+	Remaining int
+
+	// Reset is the time until the client has its full budget again.
+	Reset time.Duration
+
+	// RetryAfter is the time until the next request would be allowed, set when Allowed is false.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps rate limit counters.
+// Take counts one request for key under limit at now and reports the outcome.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit *RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+// validate reports a limit that cannot admit any request.
+func (rl *RateLimit) validate() error {
+	if rl.Limit <= 0 || rl.Window <= 0 {
+		return fmt.Errorf("%w: limit %d, window %v", ErrInvalidRateLimit, rl.Limit, rl.Window)
+	}
+	return nil
+}
+
+func (rl *RateLimit) init() {
+	rl.once.Do(func() {
+		rl.prefix = rl.Name
+		if rl.prefix == "" {
+			rl.prefix = fmt.Sprintf("%p", rl)
+		}
+		rl.prefix += ":"
+
+		rl.store = rl.Store
+		if rl.store == nil {
+			rl.store = NewMemoryRateLimitStore(0)
+		}
+	})
+}
+
+// take counts request and sets the RateLimit-* response headers.
+// Store failures go to rl.Logger, or to the one returned by logger when it is nil.
+func (rl *RateLimit) take(request *HTTPRequest, response *HTTPResponse, logger func() *slog.Logger) bool {
+	rl.init()
+
+	key := ""
+	if rl.Key != nil {
+		key = rl.Key(request)
+	}
+	if key == "" {
+		key = request.ClientIP()
+	}
+
+	result, err := rl.store.Take(request.HTTP.Context(), rl.prefix+key, rl, time.Now())
+	if err != nil {
+		log := rl.Logger
+		if log == nil {
+			log = logger()
+		}
+		log.Warn("rate limit store failed", slog.String("limit", rl.prefix), slog.Any("error", err))
+		return true
+	}
+
+	h := response.Writer.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", seconds(result.Reset))
+	if !result.Allowed {
+		h.Set("Retry-After", seconds(result.RetryAfter))
+	}
+	return result.Allowed
+}
+
+// seconds formats d as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// RateLimitMiddleware answers requests over rl with 429 Too Many Requests.
+// Path.RateLimit installs it automatically; it can also be added to Server.Middlewares
+// to limit every request.
+func RateLimitMiddleware[Payload any](rl *RateLimit) Middleware[Payload] {
+	return rateLimitMiddleware[Payload](rl, slog.Default)
+}
+
+// rateLimitMiddleware is RateLimitMiddleware logging store failures to logger() unless rl has a Logger.
+func rateLimitMiddleware[Payload any](rl *RateLimit, logger func() *slog.Logger) Middleware[Payload] {
+	return func(next HandlerFunc[Payload]) HandlerFunc[Payload] {
+		return func(request *HTTPRequest, response *HTTPResponse, payload Payload) {
+			if !rl.take(request, response, logger) {
+				response.Status(http.StatusTooManyRequests)
+				return
+			}
+			next(request, response, payload)
+		}
+	}
+}
+
+// rateLimitShards is the number of independently locked shards of a MemoryRateLimitStore.
+const rateLimitShards = 64
+
+// MemoryRateLimitStore keeps counters in memory, sharded to reduce lock contention.
+// Counters of idle clients are evicted once their budget is full again.
+type MemoryRateLimitStore struct {
+	seed    maphash.Seed
+	maxKeys int
+	shards  [rateLimitShards]rateLimitShard
+}
+
+type rateLimitShard struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	nextSweep time.Time
+}
+
+type rateLimitEntry struct {
+	// tokens is the token bucket level, count and prev the sliding window counts.
+	tokens  float64
+	count   int
+	prev    int
+	start   time.Time
+	expires time.Time
+}
+
+// NewMemoryRateLimitStore creates an in-memory store holding at most maxKeys counters.
+// When full, expired counters are evicted first and then arbitrary ones. Zero means no limit.
+func NewMemoryRateLimitStore(maxKeys int) *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{seed: maphash.MakeSeed(), maxKeys: maxKeys}
+	for i := range s.shards {
+		s.shards[i].entries = map[string]*rateLimitEntry{}
+	}
+	return s
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit *RateLimit, now time.Time) (RateLimitResult, error) {
+	shard := &s.shards[maphash.String(s.seed, key)%rateLimitShards]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.sweep(now, limit.Window)
+
+	entry, ok := shard.entries[key]
+	if !ok {
+		if s.maxKeys > 0 && len(shard.entries) >= (s.maxKeys+rateLimitShards-1)/rateLimitShards {
+			shard.evict(now)
+		}
+		entry = &rateLimitEntry{tokens: float64(limit.Limit), start: now}
+		shard.entries[key] = entry
+	}
+
+	if limit.Algorithm == SlidingWindow {
+		return entry.slidingWindow(limit, now), nil
+	}
+	return entry.tokenBucket(limit, now), nil
+}
+
+// sweep removes expired entries at most once per window.
+func (sh *rateLimitShard) sweep(now time.Time, window time.Duration) {
+	if now.Before(sh.nextSweep) {
+		return
+	}
+	sh.nextSweep = now.Add(window)
+	for key, entry := range sh.entries {
+		if now.After(entry.expires) {
+			delete(sh.entries, key)
+		}
+	}
+}
+
+// evict makes room for one entry.
+func (sh *rateLimitShard) evict(now time.Time) {
+	for key, entry := range sh.entries {
+		if now.After(entry.expires) {
+			delete(sh.entries, key)
+			return
+		}
+	}
+	for key := range sh.entries {
+		delete(sh.entries, key)
+		return
+	}
+}
+
+func (e *rateLimitEntry) tokenBucket(limit *RateLimit, now time.Time) RateLimitResult {
+	capacity := float64(limit.Limit)
+	rate := capacity / limit.Window.Seconds()
+
+	e.tokens = math.Min(capacity, e.tokens+now.Sub(e.start).Seconds()*rate)
+	e.start = now
+
+	result := RateLimitResult{Limit: limit.Limit}
+	if e.tokens >= 1 {
+		e.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - e.tokens) / rate * float64(time.Second))
+	}
+
+	result.Remaining = int(e.tokens)
+	result.Reset = time.Duration((capacity - e.tokens) / rate * float64(time.Second))
+	e.expires = now.Add(result.Reset)
+	return result
+}
+
+func (e *rateLimitEntry) slidingWindow(limit *RateLimit, now time.Time) RateLimitResult {
+	window := limit.Window
+	if elapsed := now.Sub(e.start); elapsed >= window {
+		// Move to the window containing now; the previous one only counts if it is adjacent.
+		if elapsed < 2*window {
+			e.prev = e.count
+		} else {
+			e.prev = 0
+		}
+		e.count = 0
+		e.start = e.start.Add(elapsed / window * window)
+	}
+
+	elapsed := now.Sub(e.start)
+	overlap := 1 - float64(elapsed)/float64(window)
+	used := float64(e.prev)*overlap + float64(e.count)
+
+	result := RateLimitResult{Limit: limit.Limit, Reset: window - elapsed}
+	if used+1 <= float64(limit.Limit) {
+		e.count++
+		used++
+		result.Allowed = true
+	} else if e.count+1 <= limit.Limit && e.prev > 0 {
+		// Wait until enough of the previous window has slid out.
+		needed := 1 - (float64(limit.Limit-e.count-1) / float64(e.prev))
+		result.RetryAfter = time.Duration(needed*float64(window)) - elapsed
+	} else {
+		result.RetryAfter = window - elapsed
+	}
+
+	result.Remaining = max(0, limit.Limit-int(math.Ceil(used)))
+	if e.count > 0 {
+		// Requests of the current window are counted until the next one has passed.
+		result.Reset += window
+	}
+	e.expires = e.start.Add(2 * window)
+	return result
+}
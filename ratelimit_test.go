@@ -0,0 +1,191 @@
+package streamgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingStore is a RateLimitStore that always fails.
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, *RateLimit, time.Time) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store down")
+}
+
+func TestRateLimitStoreFailureLogsToServerLogger(t *testing.T) {
+	var serverLog, limitLog bytes.Buffer
+
+	s := newTestServer(t, answerOK, []Path[string]{
+		{Name: "/server", RateLimit: &RateLimit{Limit: 1, Window: time.Second, Store: failingStore{}}},
+		{Name: "/own", RateLimit: &RateLimit{Limit: 1, Window: time.Second, Store: failingStore{}, Logger: slog.New(slog.NewTextHandler(&limitLog, nil))}},
+	})
+	// Set after BuildPaths: the logger is looked up when a failure is logged.
+	s.Logger = slog.New(slog.NewTextHandler(&serverLog, nil))
+
+	for _, url := range []string{"/server", "/own"} {
+		if w := do(s, httptest.NewRequest("GET", url, nil)); w.Code != 200 {
+			t.Errorf("GET %v = %d, want failures to let requests through", url, w.Code)
+		}
+	}
+
+	if n := strings.Count(serverLog.String(), "rate limit store failed"); n != 1 {
+		t.Errorf("server logger got %d failures, want 1:\n%s", n, serverLog.String())
+	}
+	if n := strings.Count(limitLog.String(), "rate limit store failed"); n != 1 {
+		t.Errorf("RateLimit.Logger got %d failures, want 1:\n%s", n, limitLog.String())
+	}
+}
+
+// rateLimitStep is one Take at an offset from the start of a test, with its expected result.
+type rateLimitStep struct {
+	at         time.Duration
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+func runRateLimitSteps(t *testing.T, limit *RateLimit, steps []rateLimitStep) {
+	t.Helper()
+
+	store := NewMemoryRateLimitStore(0)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	near := func(got, want time.Duration) bool {
+		return (got - want).Abs() < time.Millisecond
+	}
+	for i, step := range steps {
+		got, err := store.Take(context.Background(), "client", limit, start.Add(step.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Allowed != step.allowed || got.Remaining != step.remaining || got.Limit != limit.Limit ||
+			!near(got.Reset, step.reset) || !near(got.RetryAfter, step.retryAfter) {
+			t.Errorf("step %d at %v = %+v, want %+v", i, step.at, got, step)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	// Two tokens, refilled at one per five seconds.
+	limit := &RateLimit{Algorithm: TokenBucket, Limit: 2, Window: 10 * time.Second}
+	runRateLimitSteps(t, limit, []rateLimitStep{
+		{at: 0, allowed: true, remaining: 1, reset: 5 * time.Second},
+		{at: 0, allowed: true, remaining: 0, reset: 10 * time.Second},
+		{at: 0, allowed: false, remaining: 0, reset: 10 * time.Second, retryAfter: 5 * time.Second},
+		{at: 2500 * time.Millisecond, allowed: false, remaining: 0, reset: 7500 * time.Millisecond, retryAfter: 2500 * time.Millisecond},
+		{at: 5 * time.Second, allowed: true, remaining: 0, reset: 10 * time.Second},
+		{at: time.Minute, allowed: true, remaining: 1, reset: 5 * time.Second},
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limit := &RateLimit{Algorithm: SlidingWindow, Limit: 4, Window: 10 * time.Second}
+	runRateLimitSteps(t, limit, []rateLimitStep{
+		{at: 0, allowed: true, remaining: 3, reset: 20 * time.Second},
+		{at: 0, allowed: true, remaining: 2, reset: 20 * time.Second},
+		{at: 0, allowed: true, remaining: 1, reset: 20 * time.Second},
+		{at: 0, allowed: true, remaining: 0, reset: 20 * time.Second},
+		// The window is full and the current one alone would stay full until it ends.
+		{at: 0, allowed: false, remaining: 0, reset: 20 * time.Second, retryAfter: 10 * time.Second},
+		// Two seconds into the next window, 80% of the previous four still count: 3.2 in use.
+		// A slot frees once only three count, at 25% of the window.
+		{at: 12 * time.Second, allowed: false, remaining: 0, reset: 8 * time.Second, retryAfter: 500 * time.Millisecond},
+		{at: 12500 * time.Millisecond, allowed: true, remaining: 0, reset: 17500 * time.Millisecond},
+		// Two windows after the last request the counter has expired and starts over.
+		{at: 35 * time.Second, allowed: true, remaining: 3, reset: 20 * time.Second},
+		{at: 40 * time.Second, allowed: true, remaining: 2, reset: 15 * time.Second},
+	})
+}
+
+// sameShardKeys returns n keys that fall into the same shard of s.
+func sameShardKeys(s *MemoryRateLimitStore, n int) []string {
+	shard := maphash.String(s.seed, "k0") % rateLimitShards
+	keys := []string{"k0"}
+	for i := 1; len(keys) < n; i++ {
+		key := fmt.Sprintf("k%d", i)
+		if maphash.String(s.seed, key)%rateLimitShards == shard {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestMemoryRateLimitStoreEviction(t *testing.T) {
+	// One key per shard.
+	store := NewMemoryRateLimitStore(rateLimitShards)
+	limit := &RateLimit{Limit: 1, Window: 10 * time.Second}
+	keys := sameShardKeys(store, 2)
+	shard := &store.shards[maphash.String(store.seed, keys[0])%rateLimitShards]
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	take := func(key string, at time.Time) bool {
+		t.Helper()
+		result, err := store.Take(context.Background(), key, limit, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Allowed
+	}
+
+	if !take(keys[0], now) || take(keys[0], now) {
+		t.Fatal("first key was not limited to one request")
+	}
+	// The shard is full, so the second key evicts the first even though it has not expired.
+	if !take(keys[1], now) {
+		t.Fatal("second key was not allowed")
+	}
+	if len(shard.entries) != 1 || shard.entries[keys[1]] == nil {
+		t.Fatalf("shard holds %v, want only %v", shard.entries, keys[1])
+	}
+	if !take(keys[0], now) {
+		t.Error("evicted key kept its count")
+	}
+
+	// Once their budget is full again, entries are swept even in an unlimited store.
+	store = NewMemoryRateLimitStore(0)
+	for _, key := range keys {
+		take(key, now)
+	}
+	take(keys[0], now.Add(time.Minute))
+	shard = &store.shards[maphash.String(store.seed, keys[0])%rateLimitShards]
+	if len(shard.entries) != 1 {
+		t.Errorf("shard holds %d entries after a sweep, want 1", len(shard.entries))
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limit := &RateLimit{Limit: 2, Window: time.Minute, Key: KeyByHeader("X-Client")}
+	s := newTestServer(t, answerOK, []Path[string]{{Name: "/api", RateLimit: limit, Include: []Path[string]{{Name: "/a"}, {Name: "/b"}}}})
+
+	tests := []struct {
+		url, client string
+		status      int
+		remaining   string
+		retryAfter  string
+	}{
+		{"/api/a", "alice", 200, "1", ""},
+		// Included paths share the budget of the parent.
+		{"/api/b", "alice", 200, "0", ""},
+		{"/api/a", "alice", 429, "0", "30"},
+		{"/api/a", "bob", 200, "1", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		r.Header.Set("X-Client", tt.client)
+		w := do(s, r)
+
+		if w.Code != tt.status || w.Header().Get("RateLimit-Limit") != "2" ||
+			w.Header().Get("RateLimit-Remaining") != tt.remaining || w.Header().Get("Retry-After") != tt.retryAfter {
+			t.Errorf("%v as %v = %d %v, want %d with %v remaining and Retry-After %q",
+				tt.url, tt.client, w.Code, w.Header(), tt.status, tt.remaining, tt.retryAfter)
+		}
+	}
+}
@@ -1,21 +1,45 @@
 package streamgo
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 )
 
+var ErrCatchAllNotLast = errors.New("catch-all parameter must be the last segment")
+
+// ErrMixedSegment is returned for a segment mixing literal text and a parameter, such as
+// "user-:id:", which only the legacy regex matching supports.
+var ErrMixedSegment = errors.New("parameter must fill its whole path segment")
+
 type RegexOptions struct {
 	paramPatternRequired *regexp.Regexp
 	paramPatternOptional *regexp.Regexp
+	paramPatternCatchAll *regexp.Regexp
 	ParallelSearchCount  int
+
+	// LegacyParallelSearch matches parameterized routes with the old regex fan-out,
+	// splitting them across ParallelSearchCount goroutines per request.
+	// By default routes are matched through the compiled route tree.
+	LegacyParallelSearch bool
+
+	// constraints holds the named parameter constraints available to route names.
+	constraints map[string]ParamConstraint
 }
 
 func NewRegexOptions(parallelSearchCount int) RegexOptions {
+	constraints := make(map[string]ParamConstraint, len(DefaultParamConstraints))
+	for name, c := range DefaultParamConstraints {
+		constraints[name] = c
+	}
+
 	return RegexOptions{
 		paramPatternRequired: regexp.MustCompile(`^(.*?):[^/]+:`),
 		paramPatternOptional: regexp.MustCompile(`^(.*?)::[^/]+::`),
+		paramPatternCatchAll: regexp.MustCompile(`(^|/)(\*[^/]+|:[^/:<]+\*(<[^/]*>)?:)(/|$)`),
 		ParallelSearchCount:  parallelSearchCount,
+		constraints:          constraints,
 	}
 }
 
@@ -27,6 +51,17 @@ func (ro *RegexOptions) IsOptionalParam(name string) bool {
 	return ro.paramPatternOptional.MatchString(name)
 }
 
+// IsCatchAllParam reports whether name contains a `*name` or `:name*:` segment.
+func (ro *RegexOptions) IsCatchAllParam(name string) bool {
+	return ro.paramPatternCatchAll.MatchString(name)
+}
+
+// needsRouteTree reports whether name can only be matched through the route tree,
+// which is the case for catch-all segments and constrained parameters even in legacy mode.
+func (ro *RegexOptions) needsRouteTree(name string) bool {
+	return ro.IsCatchAllParam(name) || (ro.IsParamURL(name) && strings.ContainsRune(name, '<'))
+}
+
 func (ro *RegexOptions) IsParamURL(name string) bool {
 	return ro.IsRequiredParam(name) || ro.IsOptionalParam(name)
 }
@@ -45,13 +80,77 @@ func (ro *RegexOptions) ParseParamNames(name string) map[int]string {
 			paramName = ro.paramPatternRequired.FindStringSubmatch(v)[0]
 		}
 
-		mappedParams[i] = strings.ReplaceAll(paramName, ":", "")
-		mappedParams[i] = strings.ReplaceAll(mappedParams[i], ":", "")
+		mappedParams[i], _ = splitParam(paramName)
 	}
 
 	return mappedParams
 }
 
+// ParseSegments splits a cleaned route name into segments for the route tree
+// and returns the parameter names in the order they appear.
+// It fails when a parameter references an unknown constraint or shares its segment with literal text.
+func (ro *RegexOptions) ParseSegments(name string) ([]routeSegment, []string, error) {
+	var (
+		segments []routeSegment
+		names    []string
+	)
+
+	for _, v := range strings.Split(name, "/") {
+		if v == "" {
+			continue
+		}
+
+		if len(segments) > 0 && segments[len(segments)-1].kind == segmentCatchAll {
+			return nil, nil, ErrCatchAllNotLast
+		}
+
+		var (
+			seg   routeSegment
+			match []string
+		)
+		switch {
+		case v[0] == '*' && len(v) > 1:
+			seg.kind = segmentCatchAll
+			seg.value = v[1:]
+		case ro.IsOptionalParam(v):
+			seg.kind = segmentOptional
+			match = ro.paramPatternOptional.FindStringSubmatch(v)
+		case ro.IsRequiredParam(v):
+			seg.kind = segmentRequired
+			match = ro.paramPatternRequired.FindStringSubmatch(v)
+		default:
+			segments = append(segments, routeSegment{kind: segmentStatic, value: v})
+			continue
+		}
+		if match != nil {
+			// The patterns capture any text before the parameter, and stop at its closing colons.
+			if match[1] != "" || match[0] != v {
+				return nil, nil, fmt.Errorf("%w: %q", ErrMixedSegment, v)
+			}
+			seg.value = match[0]
+		}
+
+		paramName, constraint := splitParam(seg.value)
+		if strings.HasSuffix(paramName, "*") {
+			seg.kind = segmentCatchAll
+			paramName = strings.TrimSuffix(paramName, "*")
+		}
+		seg.value = constraint
+		if constraint != "" {
+			check, err := ro.constraint(constraint)
+			if err != nil {
+				return nil, nil, err
+			}
+			seg.check = check
+		}
+
+		segments = append(segments, seg)
+		names = append(names, paramName)
+	}
+
+	return segments, names, nil
+}
+
 func (ro *RegexOptions) ReplaceForFind(name string) string {
 	for _, v := range ro.paramPatternOptional.FindAllString(name, -1) {
 		name = strings.ReplaceAll(name, v, `?([\p{L}\p{N}\p{M}.@_-]*)?`)
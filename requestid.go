@@ -0,0 +1,143 @@
+package streamgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// DefaultRequestIDHeader is the header a request ID is read from and echoed in by default.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds accepted incoming IDs, so clients cannot inflate logs.
+const maxRequestIDLength = 128
+
+// RequestIDOptions configures how a Server assigns request IDs.
+// The ID is available through HTTPRequest.RequestID and RequestIDFromContext,
+// and is echoed in the response header.
+type RequestIDOptions struct {
+	// Header is read for an incoming ID and set on the response. It defaults to DefaultRequestIDHeader.
+	Header string
+
+	// IgnoreIncoming always generates a new ID instead of accepting the incoming header,
+	// for servers that are not behind a trusted proxy.
+	IgnoreIncoming bool
+
+	// Generator creates new IDs. It defaults to NewUUIDv7.
+	Generator func() string
+}
+
+// header returns the configured header name.
+func (o *RequestIDOptions) header() string {
+	if o.Header != "" {
+		return o.Header
+	}
+	return DefaultRequestIDHeader
+}
+
+// assign determines the ID of r, echoes it on w and returns r with the ID in its context.
+func (o *RequestIDOptions) assign(w http.ResponseWriter, r *http.Request) (string, *http.Request) {
+	header := o.header()
+
+	id := ""
+	if !o.IgnoreIncoming {
+		id = r.Header.Get(header)
+		if !isRequestID(id) {
+			id = ""
+		}
+	}
+	if id == "" {
+		if o.Generator != nil {
+			id = o.Generator()
+		} else {
+			id = NewUUIDv7()
+		}
+	}
+
+	w.Header().Set(header, id)
+	return id, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+// isRequestID reports whether an incoming ID is short and made of printable ASCII.
+func isRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDKey is the context key under which the request ID is stored.
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID stored by the server in ctx.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// RequestID returns the ID assigned by Server.RequestID.
+// When the server does not assign IDs, it returns the incoming X-Request-ID header.
+func (r *HTTPRequest) RequestID() string {
+	if r.requestID != "" {
+		return r.requestID
+	}
+	return r.HTTP.Header.Get(DefaultRequestIDHeader)
+}
+
+// NewUUIDv7 returns a random, time-ordered UUID version 7 as defined by RFC 9562.
+func NewUUIDv7() string {
+	var u [16]byte
+	_, _ = rand.Read(u[6:])
+
+	ms := uint64(time.Now().UnixMilli())
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	binary.BigEndian.PutUint32(u[2:6], uint32(ms))
+	u[6] = u[6]&0x0f | 0x70
+	u[8] = u[8]&0x3f | 0x80
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a random, lexicographically sortable ULID.
+func NewULID() string {
+	var u [16]byte
+	_, _ = rand.Read(u[6:])
+
+	ms := uint64(time.Now().UnixMilli())
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	binary.BigEndian.PutUint32(u[2:6], uint32(ms))
+
+	// 128 bits are encoded as 26 characters of 5 bits, the first one holding the top 3 bits.
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
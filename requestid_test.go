@@ -0,0 +1,120 @@
+package streamgo
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var uuidv7Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewUUIDv7(t *testing.T) {
+	before := time.Now().UnixMilli()
+	first, second := NewUUIDv7(), NewUUIDv7()
+	after := time.Now().UnixMilli()
+
+	for _, id := range []string{first, second} {
+		if !uuidv7Pattern.MatchString(id) {
+			t.Errorf("%q is not a version 7 UUID", id)
+		}
+	}
+	if first == second {
+		t.Errorf("two UUIDs are both %q", first)
+	}
+
+	// The first 48 bits are the creation time in milliseconds, so IDs sort by time.
+	ms, err := strconv.ParseInt(first[:8]+first[9:13], 16, 64)
+	if err != nil || ms < before || ms > after {
+		t.Errorf("timestamp of %q = %d, want between %d and %d", first, ms, before, after)
+	}
+	if first[:13] > second[:13] {
+		t.Errorf("%q was created after %q but sorts before it", second, first)
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	before := time.Now().UnixMilli()
+	id := NewULID()
+	after := time.Now().UnixMilli()
+
+	if len(id) != 26 || strings.Trim(id, crockford) != "" {
+		t.Fatalf("%q is not a ULID", id)
+	}
+	if id == NewULID() {
+		t.Errorf("two ULIDs are both %q", id)
+	}
+
+	// The first 10 characters encode the creation time in milliseconds.
+	var ms int64
+	for _, c := range id[:10] {
+		ms = ms<<5 | int64(strings.IndexRune(crockford, c))
+	}
+	if ms < before || ms > after {
+		t.Errorf("timestamp of %q = %d, want between %d and %d", id, ms, before, after)
+	}
+}
+
+func TestRequestIDAssignment(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *RequestIDOptions
+		header   string
+		incoming string
+		want     string // empty for a generated UUID
+	}{
+		{"generated", &RequestIDOptions{}, "X-Request-ID", "", ""},
+		{"incoming", &RequestIDOptions{}, "X-Request-ID", "abc-123", "abc-123"},
+		{"too long", &RequestIDOptions{}, "X-Request-ID", strings.Repeat("a", maxRequestIDLength+1), ""},
+		{"not printable", &RequestIDOptions{}, "X-Request-ID", "abc def", ""},
+		{"ignored", &RequestIDOptions{IgnoreIncoming: true}, "X-Request-ID", "abc-123", ""},
+		{"custom header", &RequestIDOptions{Header: "X-Trace"}, "X-Trace", "abc-123", "abc-123"},
+		{"generator", &RequestIDOptions{Generator: func() string { return "fixed" }}, "X-Request-ID", "", "fixed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromRequest, fromContext string
+			s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+				fromRequest = request.RequestID()
+				fromContext, _ = RequestIDFromContext(request.HTTP.Context())
+			}, []Path[string]{{Name: "/"}}, func(s *Server[string]) {
+				s.RequestID = tt.options
+			})
+
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				r.Header.Set(tt.header, tt.incoming)
+			}
+			echoed := do(s, r).Header().Get(tt.header)
+
+			if tt.want == "" {
+				if !uuidv7Pattern.MatchString(echoed) || echoed == tt.incoming {
+					t.Errorf("ID = %q, want a new UUID", echoed)
+				}
+			} else if echoed != tt.want {
+				t.Errorf("ID = %q, want %q", echoed, tt.want)
+			}
+			if fromRequest != echoed || fromContext != echoed {
+				t.Errorf("handler saw %q and %q in its context, want the echoed %q", fromRequest, fromContext, echoed)
+			}
+		})
+	}
+}
+
+func TestRequestIDWithoutOptions(t *testing.T) {
+	var id string
+	s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		id = request.RequestID()
+	}, []Path[string]{{Name: "/"}})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-ID", "from-proxy")
+	if w := do(s, r); w.Header().Get("X-Request-ID") != "" {
+		t.Errorf("response header = %q, want none", w.Header().Get("X-Request-ID"))
+	}
+	if id != "from-proxy" {
+		t.Errorf("RequestID = %q, want the incoming header", id)
+	}
+}
@@ -0,0 +1,195 @@
+package streamgo
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// responseWriter wraps an http.ResponseWriter and records what was sent through it.
+// It passes Flush, Hijack and ReadFrom through and supports http.ResponseController
+// via FlushError and Unwrap.
+type responseWriter struct {
+	http.ResponseWriter
+
+	status      int
+	written     int64
+	wroteHeader bool
+	hijacked    bool
+	start       time.Time
+	firstByte   time.Time
+
+	// discardBody counts but drops the body, for responses to HEAD requests.
+	// The header is then held back until finish or Flush, so it can carry the
+	// Content-Length and Content-Type that net/http derives from a GET body.
+	discardBody bool
+	pending     bool
+	sniff       []byte
+}
+
+// newResponseWriter wraps w for a request that arrived at start.
+func newResponseWriter(w http.ResponseWriter, start time.Time) *responseWriter {
+	return &responseWriter{ResponseWriter: w, start: start}
+}
+
+// WriteHeader sends the status once. Later calls are ignored instead of
+// producing superfluous WriteHeader warnings; 1xx informational codes pass through.
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader || w.hijacked {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.wroteHeader = true
+	w.status = code
+	w.firstByte = time.Now()
+	if w.discardBody {
+		w.pending = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discardBody {
+		w.discard(b)
+		return len(b), nil
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.discardBody {
+		w.discard([]byte(s))
+		return len(s), nil
+	}
+	n, err := io.WriteString(w.ResponseWriter, s)
+	w.written += int64(n)
+	return n, err
+}
+
+// ReadFrom lets io.Copy use the underlying writer's ReadFrom, such as sendfile.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.discardBody {
+		// Write counts and drops the body; the wrapper hides ReadFrom from io.Copy.
+		return io.Copy(struct{ io.Writer }{w}, r)
+	}
+
+	var (
+		n   int64
+		err error
+	)
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+	}
+	w.written += n
+	return n, err
+}
+
+// Flush sends the header and flushes the wrapped writer. It silently does nothing more
+// when the wrapped writer cannot flush; http.NewResponseController reports that case as
+// http.ErrNotSupported through FlushError.
+func (w *responseWriter) Flush() {
+	w.FlushError()
+}
+
+// FlushError is Flush reporting whether the wrapped writer could flush.
+func (w *responseWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	// Like net/http, a flushed HEAD response no longer knows its length.
+	w.sendHeader()
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+		if !w.wroteHeader {
+			w.wroteHeader = true
+			w.status = http.StatusSwitchingProtocols
+			w.firstByte = time.Now()
+		}
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// discard counts a HEAD body, keeping its start for content sniffing.
+func (w *responseWriter) discard(b []byte) {
+	if w.pending && len(w.sniff) < sniffLen {
+		w.sniff = append(w.sniff, b[:min(len(b), sniffLen-len(w.sniff))]...)
+	}
+	w.written += int64(len(b))
+}
+
+// sniffLen is how much of a body http.DetectContentType considers.
+const sniffLen = 512
+
+// sendHeader sends the status held back for a HEAD response.
+func (w *responseWriter) sendHeader() {
+	if !w.pending {
+		return
+	}
+	w.pending = false
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// finish completes a HEAD response once the handler has returned. As net/http does for a
+// GET body it fully buffered, it sets Content-Length and sniffs Content-Type unless the
+// handler set them.
+func (w *responseWriter) finish() {
+	if !w.pending {
+		return
+	}
+
+	h := w.Header()
+	_, hasType := h["Content-Type"]
+	_, hasLength := h["Content-Length"]
+	_, hasEncoding := h["Transfer-Encoding"]
+	if w.written > 0 && !hasEncoding && bodyAllowedForStatus(w.status) {
+		if !hasLength {
+			h.Set("Content-Length", strconv.FormatInt(w.written, 10))
+		}
+		if !hasType {
+			h.Set("Content-Type", http.DetectContentType(w.sniff))
+		}
+	}
+	w.sendHeader()
+}
+
+// bodyAllowedForStatus reports whether a response with status may have a body.
+func bodyAllowedForStatus(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,47 @@
+package streamgo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// plainWriter is an http.ResponseWriter without optional interfaces such as http.Flusher.
+type plainWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *plainWriter) Header() http.Header         { return w.header }
+func (w *plainWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *plainWriter) WriteHeader(status int)      { w.status = status }
+
+func TestResponseWriterFlush(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rw := newResponseWriter(recorder, time.Now())
+	if err := http.NewResponseController(rw).Flush(); err != nil || !recorder.Flushed {
+		t.Errorf("Flush = %v, flushed %v, want the recorder flushed", err, recorder.Flushed)
+	}
+
+	plain := &plainWriter{header: http.Header{}}
+	rw = newResponseWriter(plain, time.Now())
+	if err := http.NewResponseController(rw).Flush(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Flush of a writer that cannot flush = %v, want %v", err, http.ErrNotSupported)
+	}
+	if plain.status != http.StatusOK {
+		t.Errorf("status after Flush = %d, want the header sent", plain.status)
+	}
+}
+
+func TestResponseWriterTimesFromStart(t *testing.T) {
+	start := time.Now().Add(-time.Second)
+	rw := newResponseWriter(httptest.NewRecorder(), start)
+	response := HTTPResponse{Writer: rw, rw: rw}
+	response.HTML("ok")
+
+	if ttfb := response.TimeToFirstByte(); ttfb < time.Second {
+		t.Errorf("TimeToFirstByte = %v, want it measured from the start of the request", ttfb)
+	}
+}
@@ -1,8 +1,16 @@
 package streamgo
 
+import "strings"
+
 type RouteMatcher[Payload any] struct {
 	Static map[string]*Path[Payload]
 	Regex  map[string]*RouterRegex[Payload]
+
+	// tree holds every parameterized route, compiled by BuildPaths.
+	tree *routeNode[Payload]
+
+	// regexNames maps the regex of each legacy route to its route name, for conflict reports.
+	regexNames map[string]string
 }
 
 type RouterRegex[Payload any] struct {
@@ -11,3 +19,27 @@ type RouterRegex[Payload any] struct {
 	SplitedList    [][]*Path[Payload]
 	SplitedListLen int
 }
+
+// Lookup finds the endpoint for urlPath in the route tree.
+// Static routes are preferred over required parameters, which are preferred over optional ones.
+// It returns nil when no parameterized route matches.
+func (m *RouteMatcher[Payload]) Lookup(urlPath string) (*Path[Payload], map[string]string) {
+	if m.tree == nil {
+		return nil, nil
+	}
+
+	var buf [8]string
+	path, values := m.tree.match(strings.Trim(urlPath, "/"), buf[:0])
+	if path == nil {
+		return nil, nil
+	}
+
+	params := make(map[string]string, len(path.paramNames))
+	for i, name := range path.paramNames {
+		if values[i] == "" {
+			continue
+		}
+		params[name] = values[i]
+	}
+	return path, params
+}
@@ -0,0 +1,199 @@
+package streamgo
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// segmentKind identifies how a single route segment is matched.
+// The numeric order doubles as the matching priority: lower values are tried first.
+type segmentKind byte
+
+const (
+	segmentStatic segmentKind = iota
+	segmentRequired
+	segmentOptional
+	segmentCatchAll
+)
+
+// routeNode is one segment of the compiled route tree.
+// Static children are looked up by exact segment, parameter children are tried in priority order.
+type routeNode[Payload any] struct {
+	// kind describes how this node matches its segment.
+	kind segmentKind
+
+	// constraint is the constraint name of a parameter node, empty when unconstrained.
+	constraint string
+
+	// check validates parameter values when constraint is set.
+	check ParamConstraint
+
+	// static holds the children that match a literal segment.
+	static map[string]*routeNode[Payload]
+
+	// params holds the parameter children, sorted by matching priority.
+	params []*routeNode[Payload]
+
+	// path is the endpoint that terminates at this node, if any.
+	path *Path[Payload]
+
+	// pattern is the route name that registered path, used in conflict reports.
+	pattern string
+}
+
+// routeSegment is a parsed segment of a route name.
+// value holds the literal text of a static segment or the constraint name of a parameter.
+type routeSegment struct {
+	kind  segmentKind
+	value string
+	check ParamConstraint
+}
+
+// insert adds path under the given segments and returns the pattern of an
+// already registered endpoint when the route conflicts with it.
+func (n *routeNode[Payload]) insert(segments []routeSegment, path *Path[Payload], pattern string) (string, bool) {
+	node := n
+	for _, seg := range segments {
+		node = node.child(seg)
+	}
+
+	if node.path != nil {
+		return node.pattern, false
+	}
+
+	node.path = path
+	node.pattern = pattern
+	return "", true
+}
+
+// child returns the child node for seg, creating it when necessary.
+func (n *routeNode[Payload]) child(seg routeSegment) *routeNode[Payload] {
+	if seg.kind == segmentStatic {
+		if n.static == nil {
+			n.static = map[string]*routeNode[Payload]{}
+		}
+		if c, ok := n.static[seg.value]; ok {
+			return c
+		}
+		c := &routeNode[Payload]{kind: segmentStatic}
+		n.static[seg.value] = c
+		return c
+	}
+
+	for _, c := range n.params {
+		if c.kind == seg.kind && c.constraint == seg.value {
+			return c
+		}
+	}
+
+	// Constrained parameters are tried before unconstrained ones of the same kind.
+	c := &routeNode[Payload]{kind: seg.kind, constraint: seg.value, check: seg.check}
+	n.params = append(n.params, c)
+	sort.SliceStable(n.params, func(i, j int) bool {
+		a, b := n.params[i], n.params[j]
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.constraint != "" && b.constraint == ""
+	})
+	return c
+}
+
+// match walks the tree for the slash separated path and returns the endpoint
+// together with the captured parameter values in route order.
+// An absent optional parameter is recorded as an empty value.
+func (n *routeNode[Payload]) match(path string, values []string) (*Path[Payload], []string) {
+	if path == "" {
+		if n.path != nil {
+			return n.path, values
+		}
+		for _, c := range n.params {
+			switch c.kind {
+			case segmentOptional:
+				if p, v := c.match("", append(values, "")); p != nil {
+					return p, v
+				}
+			case segmentCatchAll:
+				if c.path != nil && (c.check == nil || c.check("")) {
+					return c.path, append(values, "")
+				}
+			}
+		}
+		return nil, values
+	}
+
+	seg, rest := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		seg, rest = path[:i], path[i+1:]
+	}
+
+	if c, ok := n.static[seg]; ok {
+		if p, v := c.match(rest, values); p != nil {
+			return p, v
+		}
+	}
+
+	for _, c := range n.params {
+		switch c.kind {
+		case segmentRequired:
+			if !c.accepts(seg) {
+				continue
+			}
+			if p, v := c.match(rest, append(values, seg)); p != nil {
+				return p, v
+			}
+		case segmentOptional:
+			if seg == "" || c.accepts(seg) {
+				if p, v := c.match(rest, append(values, seg)); p != nil {
+					return p, v
+				}
+			}
+			// The optional segment may also be left out entirely.
+			if p, v := c.match(path, append(values, "")); p != nil {
+				return p, v
+			}
+		case segmentCatchAll:
+			// A catch-all is always the last segment and captures the rest of the path.
+			if c.path != nil && (c.check == nil || c.check(path)) {
+				return c.path, append(values, path)
+			}
+		}
+	}
+
+	return nil, values
+}
+
+// accepts reports whether seg is a valid value for this parameter node.
+func (n *routeNode[Payload]) accepts(seg string) bool {
+	if n.check != nil {
+		return seg != "" && n.check(seg)
+	}
+	return isParamValue(seg)
+}
+
+// isParamValue reports whether s is a valid parameter value.
+// It accepts the same characters as the legacy pattern `[\p{L}\p{N}\p{M}.@_-]+`.
+func isParamValue(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+				c == '.' || c == '@' || c == '_' || c == '-') {
+				return false
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r) {
+			return false
+		}
+		i += size
+	}
+	return true
+}
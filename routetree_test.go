@@ -0,0 +1,250 @@
+package streamgo
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newRouteServer builds a server whose handler answers with the route payload
+// followed by the captured params, and "404" when nothing matched.
+func newRouteServer(t *testing.T, legacy bool, names ...string) *Server[string] {
+	t.Helper()
+
+	paths := make([]Path[string], len(names))
+	for i, name := range names {
+		paths[i] = Path[string]{Name: name, Payload: name}
+	}
+	return newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		params := make([]string, 0, len(request.Params))
+		for k, v := range request.Params {
+			params = append(params, k+"="+v)
+		}
+		sort.Strings(params)
+		response.HTML(payload + "|" + strings.Join(params, ","))
+	}, paths, func(s *Server[string]) {
+		s.RegexOptions.ParallelSearchCount = 2
+		s.RegexOptions.LegacyParallelSearch = legacy
+	})
+}
+
+func TestRouteTreePrecedence(t *testing.T) {
+	s := newRouteServer(t, false,
+		"/u/me",
+		"/u/:id:",
+		"/u/:id:/::tab::",
+		"/c/:n<int>:/x",
+		"/c/:s:/x",
+		"/b/:a:/end",
+		"/b/static/other",
+		"/o/:r:",
+		"/o/::opt::",
+		"/f/:one:",
+		"/f/*rest",
+		"/files/*rest",
+		"/d/:day<date>:",
+		"/re/:code<re:[A-Z]{3}>:",
+	)
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"static beats required", "/u/me", "/u/me|"},
+		{"required", "/u/5", "/u/:id:|id=5"},
+		{"trailing slash", "/u/5/", "/u/:id:|id=5"},
+		{"optional present", "/u/5/posts", "/u/:id:/::tab::|id=5,tab=posts"},
+		{"constrained before unconstrained", "/c/42/x", "/c/:n<int>:/x|n=42"},
+		{"constraint rejects, falls back", "/c/abc/x", "/c/:s:/x|s=abc"},
+		{"backtracks out of static branch", "/b/static/end", "/b/:a:/end|a=static"},
+		{"static branch still matches", "/b/static/other", "/b/static/other|"},
+		{"required beats optional", "/o/x", "/o/:r:|r=x"},
+		{"optional left out", "/o", "/o/::opt::|"},
+		{"required beats catch-all", "/f/a", "/f/:one:|one=a"},
+		{"catch-all takes the rest", "/f/a/b", "/f/*rest|rest=a/b"},
+		{"catch-all deep", "/files/a/b/c.txt", "/files/*rest|rest=a/b/c.txt"},
+		{"catch-all empty", "/files", "/files/*rest|"},
+		{"date constraint", "/d/2024-01-02", "/d/:day<date>:|day=2024-01-02"},
+		{"date constraint rejects", "/d/2024-13-01", "404"},
+		{"regex constraint", "/re/ABC", "/re/:code<re:[A-Z]{3}>:|code=ABC"},
+		{"regex constraint rejects", "/re/abc", "404"},
+		{"unicode value", "/u/çağrı", "/u/:id:|id=çağrı"},
+		{"invalid value", "/u/a%20b", "404"},
+		{"too deep", "/u/5/posts/extra", "404"},
+		{"unknown", "/nope", "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(s, "GET", tt.url); got != tt.want {
+				t.Errorf("GET %s = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteTreeLegacy(t *testing.T) {
+	s := newRouteServer(t, true,
+		"/u/me",
+		"/u/:id:",
+		"/c/:n<int>:",
+		"/files/*rest",
+		"/p/user-:id:",
+	)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/u/me", "/u/me|"},
+		{"/u/5", "/u/:id:|id=5"},
+		// Constrained and catch-all routes go through the tree even in legacy mode.
+		{"/c/42", "/c/:n<int>:|n=42"},
+		{"/c/abc", "404"},
+		{"/files/a/b", "/files/*rest|rest=a/b"},
+		// Mixed segments are only supported by the regex matching, which keeps the literal prefix.
+		{"/p/user-5", "/p/user-:id:|id=5"},
+		{"/p/5", "404"},
+		{"/nope", "404"},
+	}
+	for _, tt := range tests {
+		if got := serve(s, "GET", tt.url); got != tt.want {
+			t.Errorf("GET %s = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRouteTreeConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		legacy   bool
+		names    []string
+		existing string
+	}{
+		{"same shape, different names", false, []string{"/u/:a:", "/u/:b:"}, "/u/:a:/"},
+		{"same constraint", false, []string{"/u/:a<int>:", "/u/:b<int>:"}, "/u/:a<int>:/"},
+		{"same optional", false, []string{"/u/::a::", "/u/::b::"}, "/u/::a::/"},
+		{"same catch-all", false, []string{"/f/*a", "/f/*b"}, "/f/*a/"},
+		{"static duplicate", false, []string{"/s", "/s/"}, "/s/"},
+		{"legacy regex duplicate", true, []string{"/u/:a:", "/u/:b:"}, "/u/:a:/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := NewRegexOptions(1)
+			opts.LegacyParallelSearch = tt.legacy
+			s := NewServer[string](opts)
+
+			paths := make([]Path[string], len(tt.names))
+			for i, name := range tt.names {
+				paths[i] = Path[string]{Name: name}
+			}
+			err := s.BuildPaths(paths, "")
+
+			var dup *DuplicateRouteError
+			if !errors.Is(err, ErrDuplicateRoute) || !errors.As(err, &dup) {
+				t.Fatalf("BuildPaths = %v, want a DuplicateRouteError", err)
+			}
+			if dup.Existing != tt.existing {
+				t.Errorf("Existing = %q, want %q", dup.Existing, tt.existing)
+			}
+		})
+	}
+}
+
+func TestRouteTreeNoConflict(t *testing.T) {
+	newRouteServer(t, false, "/u/:a<int>:", "/u/:b:", "/u/::c::/x", "/u/*rest")
+}
+
+func TestRouteTreeInvalidNames(t *testing.T) {
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"/f/*rest/more", ErrCatchAllNotLast},
+		{"/u/:id<nope>:", ErrUnknownConstraint},
+		{"/u/:id<re:[>:", ErrUnknownConstraint},
+		{"/user-:id:", ErrMixedSegment},
+		{"/u/:id:.json", ErrMixedSegment},
+		{"/u/v::opt::", ErrMixedSegment},
+		{"/u/x:id<int>:", ErrMixedSegment},
+	}
+	for _, tt := range tests {
+		s := NewServer[string](NewRegexOptions(1))
+		err := s.BuildPaths([]Path[string]{{Name: tt.name}}, "")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("BuildPaths(%q) = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestIsParamValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"abc", true},
+		{"a.b@c_d-e", true},
+		{"日本", true},
+		{"", false},
+		{"a b", false},
+		{"a/b", false},
+		{"a%b", false},
+	}
+	for _, tt := range tests {
+		if got := isParamValue(tt.value); got != tt.want {
+			t.Errorf("isParamValue(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRouteTreeCatchAll(t *testing.T) {
+	s := newRouteServer(t, false,
+		"/files/*path",
+		"/files/readme",
+		"/api/:version:/*rest",
+		"/s/:rest*:",
+		`/docs/:page*<re:\D+>:`,
+	)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/files/a", "/files/*path|path=a"},
+		{"/files/a/b/c.txt", "/files/*path|path=a/b/c.txt"},
+		// Static routes win over a catch-all at the same level.
+		{"/files/readme", "/files/readme|"},
+		{"/files/readme/more", "/files/*path|path=readme/more"},
+		{"/files", "/files/*path|"},
+		{"/api/v1/users/5", "/api/:version:/*rest|rest=users/5,version=v1"},
+		{"/s/x/y", "/s/:rest*:|rest=x/y"},
+		// A constrained catch-all checks the whole remainder.
+		{"/docs/guide/intro", `/docs/:page*<re:\D+>:|page=guide/intro`},
+		{"/docs/guide/2", "404"},
+	}
+	for _, tt := range tests {
+		if got := serve(s, "GET", tt.url); got != tt.want {
+			t.Errorf("GET %s = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestIsCatchAllParam(t *testing.T) {
+	ro := NewRegexOptions(1)
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"/files/*path", true},
+		{"/files/:path*:", true},
+		{"/files/:path*<re:.+>:", true},
+		{"/files/:path:", false},
+		{"/files/a*b", false},
+	}
+	for _, tt := range tests {
+		if got := ro.IsCatchAllParam(tt.name); got != tt.want {
+			t.Errorf("IsCatchAllParam(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,52 +2,265 @@ package streamgo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"regexp"
+	"runtime/debug"
 	"sync"
 
 	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+var (
+	ErrDuplicateRoute = errors.New("duplicate route")
+	ErrNoListener     = errors.New("no address or unix socket path provided")
+)
+
+// DuplicateRouteError reports a route that conflicts with an already registered one.
+// It matches ErrDuplicateRoute with errors.Is.
+type DuplicateRouteError struct {
+	// Pattern is the route name that was being registered.
+	Pattern string
+
+	// Existing is the route name it conflicts with.
+	Existing string
+}
+
+func (e *DuplicateRouteError) Error() string {
+	return fmt.Sprintf("%v: %v conflicts with %v", ErrDuplicateRoute, e.Pattern, e.Existing)
+}
+
+func (e *DuplicateRouteError) Unwrap() error {
+	return ErrDuplicateRoute
+}
+
+// HandlerFunc handles a request routed to an endpoint carrying payload.
+type HandlerFunc[Payload any] func(request *HTTPRequest, response *HTTPResponse, payload Payload)
+
 type Server[Payload any] struct {
-	Paths            RouteMatcher[Payload]
-	RegexOptions     *RegexOptions
-	HTTPHandle404    func(request *HTTPRequest, response *HTTPResponse, payload Payload)
-	HTTPHandle405    func(request *HTTPRequest, response *HTTPResponse, payload Payload)
-	HTTPHandler      func(request *HTTPRequest, response *HTTPResponse, payload Payload)
+	Paths         RouteMatcher[Payload]
+	RegexOptions  *RegexOptions
+	HTTPHandle404 HandlerFunc[Payload]
+	HTTPHandle405 HandlerFunc[Payload]
+	HTTPHandler   HandlerFunc[Payload]
+
+	// HTTPHandle500 is called after a handler panicked, with the recovered value and the stack.
+	// A 500 status is sent afterwards if nothing was written yet.
+	HTTPHandle500 func(request *HTTPRequest, response *HTTPResponse, payload Payload, recovered any, stack []byte)
+
+	// Logger receives the server's structured logs. It defaults to slog.Default().
+	Logger *slog.Logger
+
+	// AccessLog emits one record per request when set.
+	AccessLog *AccessLogOptions
+
+	// RequestID assigns every request an ID when set. See RequestIDOptions.
+	RequestID *RequestIDOptions
+
+	// Tracing propagates W3C Trace Context headers and records a span per request when set.
+	Tracing *TracingOptions
+
+	// Metrics collects request and connection metrics when set. Serve them with MetricsPath.
+	Metrics *Metrics
+
+	// ConcurrencyLimit caps how many requests the server runs at once when set. Slots are
+	// taken after the route lookup, before any middleware and any route's own ConcurrencyLimit,
+	// and are held until the response is complete. WebSocket upgrades are not counted, as they
+	// would hold a slot for the whole connection; give their routes a Path.ConcurrencyLimit.
+	// An invalid limit makes BuildPaths, when it is already set, and ListenAll fail; when
+	// ServeHTTP is called directly, Compile logs it and every request is answered with 500.
+	ConcurrencyLimit *ConcurrencyLimit
+
+	// ClientIPResolver determines HTTPRequest.ClientIP, and HTTPRequest.IP when it is called
+	// without trusted proxies. Without a resolver, the peer address is the client IP.
+	ClientIPResolver *ClientIPResolver
+
+	// Middlewares run for every request, outside of any route middleware,
+	// including requests answered by HTTPHandle404 and HTTPHandle405.
+	// They are composed with the route middleware by Compile.
+	Middlewares []Middleware[Payload]
+
+	// CORS is the default cross-origin policy of routes without one of their own.
+	// It applies to routes registered by later BuildPaths calls.
+	CORS *CORSPolicy
+
+	// WebSocketHandler serves WebSocket upgrade requests.
+	// Only connections upgraded through HTTPResponse.UpgradeWebSocket receive a close frame
+	// during a graceful shutdown; connections upgraded by calling upgrader.Upgrade on
+	// response.Writer directly are not tracked, and are neither notified nor closed by the server.
+	// Upgrade requests arriving once a shutdown has begun are answered with 503 Service Unavailable.
 	WebSocketHandler func(request *HTTPRequest, response *HTTPResponse, payload Payload, upgrader *websocket.Upgrader)
+
+	// ShutdownTimeout enables graceful shutdown when positive.
+	// Once the Listen context is cancelled the listeners stop accepting connections,
+	// WebSocket clients receive a close frame and in-flight requests get this long to finish.
+	// When zero, every server is closed immediately.
+	ShutdownTimeout time.Duration
+
+	// Options configures the http.Server of every listener started by Listen.
+	Options ServerOptions
+
+	// TLS makes the TCP listener started by Listen serve TLS when set.
+	// Listeners passed to ListenAll carry their own TLS settings.
+	TLS *TLSOptions
+
+	// UnixSocket configures the socket file created by Listen.
+	// Listeners passed to ListenAll carry their own unix socket settings.
+	UnixSocket *UnixSocketOptions
+
+	// TCPServer and UnixSocketServer are the first TCP and unix socket servers started by Listen or ListenAll.
+	TCPServer        *http.Server
+	UnixSocketServer *http.Server
+
+	// unixListener is the listener behind UnixSocketServer.
+	unixListener net.Listener
+
+	// webSockets tracks connections upgraded through HTTPResponse.UpgradeWebSocket.
+	webSockets *webSocketTracker
+
+	// active records the listeners served by ListenAll for Handoff.
+	active *activeListeners
+
+	// routes lists every endpoint registered by BuildPaths, for Compile.
+	routes []*Path[Payload]
+
+	// handle404 is HTTPHandle404 behind Middlewares, composed by Compile.
+	handle404 HandlerFunc[Payload]
 }
 
-func NewServer[PayloadType any](regexOpts RegexOptions) Server[PayloadType] {
+// NewServer creates a server matching routes with regexOpts.
+// The first of serverOpts, if any, configures the listeners started by Listen.
+func NewServer[PayloadType any](regexOpts RegexOptions, serverOpts ...ServerOptions) Server[PayloadType] {
 	if regexOpts.paramPatternOptional == nil || regexOpts.paramPatternRequired == nil {
-		regexOpts = NewRegexOptions(regexOpts.ParallelSearchCount)
+		opts := NewRegexOptions(regexOpts.ParallelSearchCount)
+		opts.LegacyParallelSearch = regexOpts.LegacyParallelSearch
+		for name, c := range regexOpts.constraints {
+			opts.constraints[name] = c
+		}
+		regexOpts = opts
 	}
-	return Server[PayloadType]{
+	server := Server[PayloadType]{
 		RegexOptions: &regexOpts,
+		webSockets:   newWebSocketTracker(),
+		active:       &activeListeners{},
 	}
+	if len(serverOpts) > 0 {
+		server.Options = serverOpts[0]
+	}
+	return server
+}
+
+// BuildPaths registers paths and their Include trees under perfix.
+// It returns a *DuplicateRouteError when two routes match the same requests,
+// or an error describing an invalid route name or policy.
+func (s *Server[PayloadType]) BuildPaths(paths []Path[PayloadType], perfix string) error {
+	if s.ConcurrencyLimit != nil {
+		if err := s.ConcurrencyLimit.init(); err != nil {
+			return err
+		}
+	}
+	cors, err := compileCORS(s.CORS)
+	if err != nil {
+		return fmt.Errorf("invalid CORS policy: %w", err)
+	}
+	return s.buildPaths(paths, perfix, routeScope[PayloadType]{cors: cors})
 }
 
-func (s *Server[PayloadType]) BuildPaths(paths []Path[PayloadType], perfix string) {
+// routeScope carries the settings a Path passes down to its Include children.
+type routeScope[Payload any] struct {
+	middlewares []Middleware[Payload]
+	cors        *corsConfig
+	rateLimit   *RateLimit
+	concurrency *ConcurrencyLimit
+}
+
+func (s *Server[PayloadType]) buildPaths(paths []Path[PayloadType], perfix string, scope routeScope[PayloadType]) error {
 	var fullname strings.Builder
-	var usednames map[string]bool = map[string]bool{}
 
 	for i := 0; i < len(paths); i++ {
 		fullname.WriteString(perfix)
 		fullname.WriteString(paths[i].Name)
 
 		name := ClearURL(fullname.String())
+		paths[i].middlewares = inheritMiddlewares(scope.middlewares, paths[i].Middlewares)
+		paths[i].cors = scope.cors
+		if paths[i].CORS != nil {
+			cors, err := compileCORS(paths[i].CORS)
+			if err != nil {
+				return fmt.Errorf("invalid CORS policy for %v: %w", name, err)
+			}
+			paths[i].cors = cors
+		}
+
+		rateLimit := scope.rateLimit
+		if paths[i].RateLimit != nil {
+			if err := paths[i].RateLimit.validate(); err != nil {
+				return fmt.Errorf("invalid rate limit for %v: %w", name, err)
+			}
+			rateLimit = paths[i].RateLimit
+		}
+
+		concurrency := scope.concurrency
+		if paths[i].ConcurrencyLimit != nil {
+			if err := paths[i].ConcurrencyLimit.init(); err != nil {
+				return fmt.Errorf("invalid concurrency limit for %v: %w", name, err)
+			}
+			concurrency = paths[i].ConcurrencyLimit
+		}
+
 		if paths[i].Include != nil {
-			s.BuildPaths(paths[i].Include, name)
+			child := routeScope[PayloadType]{middlewares: paths[i].middlewares, cors: paths[i].cors, rateLimit: rateLimit, concurrency: concurrency}
+			if err := s.buildPaths(paths[i].Include, name, child); err != nil {
+				return err
+			}
+		}
+
+		// Limiters run before any route middleware and are not passed on as one,
+		// so Include children count each request once.
+		var limiters []Middleware[PayloadType]
+		if rateLimit != nil {
+			limiters = append(limiters, rateLimitMiddleware[PayloadType](rateLimit, s.logger))
+		}
+		if concurrency != nil {
+			limiter, err := ConcurrencyLimitMiddleware[PayloadType](concurrency)
+			if err != nil {
+				return fmt.Errorf("invalid concurrency limit for %v: %w", name, err)
+			}
+			limiters = append(limiters, limiter)
+		}
+		if limiters != nil {
+			paths[i].middlewares = inheritMiddlewares(limiters, paths[i].middlewares)
 		}
 
 		fullname.Reset()
+		paths[i].pattern = name
+		s.routes = append(s.routes, &paths[i])
 		paths[i].NormalizeMethods()
-		if s.RegexOptions.IsParamURL(name) {
+		paths[i].allow = strings.Join(paths[i].AllowedMethods(), ", ")
+		if s.RegexOptions.needsRouteTree(name) || (s.RegexOptions.IsParamURL(name) && !s.RegexOptions.LegacyParallelSearch) {
+			segments, names, err := s.RegexOptions.ParseSegments(name)
+			if err != nil {
+				return fmt.Errorf("invalid URL path %v: %w", name, err)
+			}
+			paths[i].paramNames = names
+
+			if s.Paths.tree == nil {
+				s.Paths.tree = &routeNode[PayloadType]{}
+			}
+
+			if existing, ok := s.Paths.tree.insert(segments, &paths[i], name); !ok {
+				return &DuplicateRouteError{Pattern: name, Existing: existing}
+			}
+
+		} else if s.RegexOptions.IsParamURL(name) {
 			perfix := s.RegexOptions.GetPerfix(name)
 			unPerfixed := name[len(perfix):]
 
@@ -61,6 +274,9 @@ func (s *Server[PayloadType]) BuildPaths(paths []Path[PayloadType], perfix strin
 			}
 
 			fullName := "^" + ClearURL(regexName.String()) + "$"
+			if existing, ok := s.Paths.regexNames[fullName]; ok {
+				return &DuplicateRouteError{Pattern: name, Existing: existing}
+			}
 			paths[i].regexName = regexp.MustCompile(fullName)
 
 			if s.Paths.Regex == nil {
@@ -76,10 +292,10 @@ func (s *Server[PayloadType]) BuildPaths(paths []Path[PayloadType], perfix strin
 
 			s.Paths.Regex[perfix].List = append(s.Paths.Regex[perfix].List, &paths[i])
 
-			if _, ok := usednames[fullName]; ok {
-				log.Fatalf("This URL path already exists: %v", name)
+			if s.Paths.regexNames == nil {
+				s.Paths.regexNames = map[string]string{}
 			}
-			usednames[fullName] = true
+			s.Paths.regexNames[fullName] = name
 
 		} else {
 			if s.Paths.Static == nil {
@@ -87,7 +303,7 @@ func (s *Server[PayloadType]) BuildPaths(paths []Path[PayloadType], perfix strin
 			}
 
 			if _, ok := s.Paths.Static[name]; ok {
-				log.Fatalf("This URL path already exists: %v", name)
+				return &DuplicateRouteError{Pattern: name, Existing: name}
 			}
 
 			s.Paths.Static[name] = &paths[i]
@@ -99,113 +315,354 @@ func (s *Server[PayloadType]) BuildPaths(paths []Path[PayloadType], perfix strin
 
 	}
 
+	return nil
 }
-func (s *Server[PayloadType]) Listen(ctx context.Context, addr, unixSocketPath string) {
+
+// Compile prepares the registered routes for serving and composes their middleware chains.
+// It must be called after the last BuildPaths call or change to Middlewares or ConcurrencyLimit,
+// and before ServeHTTP is used with another http.Server or in tests; Listen calls it itself.
+func (s *Server[PayloadType]) Compile() {
 	// Regex yollarını işle
 	for i := range s.Paths.Regex {
 		s.Paths.Regex[i].SplitedList = SplitArray(s.Paths.Regex[i].List, s.RegexOptions.ParallelSearchCount)
 		s.Paths.Regex[i].SplitedListLen = len(s.Paths.Regex[i].SplitedList)
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", s.mainHandler)
+	for _, path := range s.routes {
+		path.handle = Chain(s.routeHandler(path), s.Middlewares, path.middlewares)
+	}
+	s.handle404 = Chain(s.notFoundHandler, s.Middlewares)
 
-	errCh := make(chan error, 2)
+	if s.ConcurrencyLimit != nil {
+		if err := s.ConcurrencyLimit.init(); err != nil {
+			s.logger().Error("invalid concurrency limit", slog.Any("error", err))
+		}
+	}
 
-	if addr != "" {
-		s.TCPServer = &http.Server{Addr: addr, Handler: mux}
-		go func() {
-			go func() {
-				<-ctx.Done()
-				if err := s.TCPServer.Close(); err != nil {
-					log.Printf("Error closing TCP server: %v", err)
-				}
-			}()
-			if err := s.TCPServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				errCh <- err
-			}
-		}()
+	if s.webSockets == nil {
+		s.webSockets = newWebSocketTracker()
+	}
+	if s.active == nil {
+		s.active = &activeListeners{}
 	}
+}
 
-	if unixSocketPath != "" {
-		if _, err := os.Stat(unixSocketPath); err == nil {
-			if err := os.Remove(unixSocketPath); err != nil {
-				log.Fatalf("Failed to remove existing socket file: %v", err)
-			}
-		}
+// shutdown stops servers once the Listen context is cancelled.
+// Without a ShutdownTimeout the servers are closed right away. Otherwise they stop
+// accepting connections, WebSocket clients are asked to go away, and shutdown waits
+// for in-flight requests and WebSocket handlers until the timeout passes.
+func (s *Server[PayloadType]) shutdown(servers []*http.Server, socketPaths []string) error {
+	defer s.removeSockets(socketPaths)
 
-		listener, err := net.Listen("unix", unixSocketPath)
-		if err != nil {
-			log.Fatalf("Error creating Unix socket: %v", err)
-		}
-		s.unixListener = listener
+	if s.ShutdownTimeout <= 0 {
+		closeServers(servers)
+		return nil
+	}
 
-		if err := os.Chmod(unixSocketPath, 0666); err != nil {
-			log.Fatalf("Error setting socket permissions: %v", err)
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
 
-		s.UnixSocketServer = &http.Server{Handler: mux}
+	var wg sync.WaitGroup
+	errs := make([]error, len(servers)+1)
+	for i, srv := range servers {
+		wg.Add(1)
 		go func() {
-			go func() {
-				<-ctx.Done()
-				if err := s.UnixSocketServer.Close(); err != nil {
-					log.Printf("Error closing Unix socket server: %v", err)
-				}
-			}()
-			if err := s.UnixSocketServer.Serve(listener); err != nil && err != http.ErrServerClosed {
-				errCh <- err
-			}
+			defer wg.Done()
+			errs[i] = srv.Shutdown(ctx)
 		}()
 	}
 
-	if addr == "" && unixSocketPath == "" {
-		log.Println("No address or Unix socket path provided; exiting")
+	deadline, _ := ctx.Deadline()
+	s.webSockets.goAway(deadline)
+
+	wg.Wait()
+	errs[len(servers)] = s.webSockets.wait(ctx)
+
+	if ctx.Err() != nil {
+		closeServers(servers)
+		s.webSockets.closeAll()
+		return fmt.Errorf("graceful shutdown: %w", ctx.Err())
+	}
+	return errors.Join(errs...)
+}
+
+func closeServers(servers []*http.Server) {
+	for _, srv := range servers {
+		if err := srv.Close(); err != nil {
+			log.Printf("Error closing server: %v", err)
+		}
+	}
+}
+
+// removeSockets deletes the socket files created by ListenAll,
+// unless Handoff passed them to a child process that still serves them.
+func (s *Server[PayloadType]) removeSockets(socketPaths []string) {
+	if s.active.keepSockets() {
 		return
 	}
+	for _, p := range socketPaths {
+		removeSocket(p)
+	}
+}
 
-	select {
-	case <-ctx.Done():
-		log.Println("Server stopped due to context cancellation")
-	case err := <-errCh:
-		log.Fatalf("Server error: %v", err)
+// removeSocket deletes the unix socket file left behind by a listener.
+func removeSocket(unixSocketPath string) {
+	if unixSocketPath == "" {
+		return
+	}
+	if err := os.Remove(unixSocketPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing Unix socket file: %v", err)
 	}
 }
 
-func (s *Server[PayloadType]) mainHandler(w http.ResponseWriter, r *http.Request) {
+// ServeHTTP routes the request to its endpoint, making Server usable as an http.Handler.
+func (s *Server[PayloadType]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Durations and the time to first byte include routing.
+	start := time.Now()
+
 	var path *Path[PayloadType]
-	var params map[string]string = map[string]string{}
+	var params map[string]string
 
 	if p, ok := s.Paths.Static[r.URL.Path]; ok {
 		path = p
-	} else if path == nil {
+	} else {
+		if s.RegexOptions.LegacyParallelSearch {
+			path, params = s.legacyMatch(r)
+		}
+		if path == nil {
+			path, params = s.Paths.Lookup(r.URL.Path)
+		}
+	}
 
-		for perfix, v := range s.Paths.Regex {
+	if params == nil {
+		params = map[string]string{}
+	}
 
-			if p := r.URL.Path; len(p) == 0 || p[len(p)-1] != '/' {
-				r.URL.Path += "/"
-			}
+	var requestID string
+	if s.RequestID != nil {
+		requestID, r = s.RequestID.assign(w, r)
+	}
+
+	request := HTTPRequest{HTTP: r, Params: params, ipResolver: s.ClientIPResolver, requestID: requestID}
+	rw := newResponseWriter(w, start)
+	response := HTTPResponse{Writer: rw, webSockets: s.webSockets, rw: rw, metrics: s.Metrics}
+
+	// label names the route in metrics and spans, where requests that matched nothing
+	// share one value to keep cardinality bounded.
+	var route string
+	label := unmatchedRoute
+	if path != nil {
+		route = path.pattern
+		label = route
+	}
+
+	// Deferred first so they run last, after a recovered panic set the status.
+	if s.AccessLog != nil {
+		defer s.AccessLog.log(&request, &response, route)
+	}
+	if s.Metrics != nil {
+		s.Metrics.begin(label)
+		defer s.Metrics.end(label, &request, &response)
+	}
+	if s.Tracing != nil {
+		span := s.Tracing.startSpan(r, label)
+		request.HTTP = r.WithContext(context.WithValue(r.Context(), spanKey{}, span))
+		w.Header().Set(traceparentHeader, span.Context.Traceparent())
+		if span.Context.TraceState != "" {
+			w.Header().Set(tracestateHeader, span.Context.TraceState)
+		}
+		defer s.Tracing.endSpan(span, &response)
+	}
+
+	if s.ConcurrencyLimit != nil && !request.IsWebSocketConnection() {
+		// Compile validated the limit and logged it when invalid.
+		if s.ConcurrencyLimit.err != nil {
+			response.Status(http.StatusInternalServerError)
+			return
+		}
+		if !s.ConcurrencyLimit.acquire(request.HTTP.Context()) {
+			s.ConcurrencyLimit.reject(&response)
+			return
+		}
+		start := time.Now()
+		defer func() { s.ConcurrencyLimit.release(time.Since(start)) }()
+	}
+
+	if path == nil {
+		var zeroValue PayloadType
+		defer s.recoverPanic(&request, &response, zeroValue)
+		handle := s.handle404
+		if handle == nil {
+			handle = Chain(s.notFoundHandler, s.Middlewares)
+		}
+		handle(&request, &response, zeroValue)
+		return
+	}
+
+	// Deferred before recoverPanic, so a HEAD response is completed after a recovered panic.
+	defer rw.finish()
+	defer s.recoverPanic(&request, &response, path.Payload)
 
-			if !strings.HasPrefix(r.URL.Path, perfix) {
-				continue
+	if request.IsWebSocketConnection() {
+		if s.webSockets != nil {
+			if !s.webSockets.begin() {
+				// The server is shutting down and no longer waits for new handlers.
+				response.Status(http.StatusServiceUnavailable)
+				return
 			}
+			defer s.webSockets.end()
+		}
+		defer response.releaseWebSockets()
+	} else {
+		s.prepareResponse(path, &request, &response)
+	}
 
-			var wg sync.WaitGroup
-			ctx, cancel := context.WithCancel(r.Context())
-			defer cancel()
-			for i := 0; i < v.SplitedListLen; i++ {
-				wg.Add(1)
-				go func(i int) {
-					defer wg.Done()
-					for i2 := 0; i2 < len(v.SplitedList[i]); i2++ {
-						// Eğer birisi sonucu bulmuşsa, çık
-						select {
-						case <-ctx.Done():
-							return
-						default:
-							if !v.SplitedList[i][i2].regexName.MatchString(r.URL.Path) {
-								continue
-							}
-							cancel()
+	handle := path.handle
+	if handle == nil {
+		// Compile was not called after the route was registered.
+		handle = Chain(s.routeHandler(path), s.Middlewares, path.middlewares)
+	}
+	handle(&request, &response, path.Payload)
+}
+
+// notFoundHandler calls HTTPHandle404, looked up per request so it may be set after Compile.
+func (s *Server[PayloadType]) notFoundHandler(request *HTTPRequest, response *HTTPResponse, payload PayloadType) {
+	s.HTTPHandle404(request, response, payload)
+}
+
+// routeHandler returns the innermost handler of path, which middleware is composed around.
+// It hands WebSocket upgrades to WebSocketHandler and other requests to methodHandler.
+func (s *Server[PayloadType]) routeHandler(path *Path[PayloadType]) HandlerFunc[PayloadType] {
+	return func(request *HTTPRequest, response *HTTPResponse, payload PayloadType) {
+		if request.IsWebSocketConnection() {
+			s.WebSocketHandler(request, response, payload, path.WebSocket.Upgrader)
+			return
+		}
+		s.methodHandler(path, request, response)(request, response, payload)
+	}
+}
+
+// prepareResponse sets what every response of path needs before any middleware runs:
+// the CORS headers of a non-preflight request, and body discarding for HEAD.
+func (s *Server[PayloadType]) prepareResponse(path *Path[PayloadType], request *HTTPRequest, response *HTTPResponse) {
+	if path.cors != nil && !isPreflight(request.HTTP) {
+		path.cors.applyCORS(request.HTTP, response.Writer.Header())
+	}
+	if request.Method() == string(HEAD) {
+		// net/http drops HEAD bodies itself, but ServeHTTP may be driven by other callers.
+		// The headers still describe the body a GET would have got.
+		response.recorder().discardBody = true
+	}
+}
+
+// methodHandler picks the handler for the request method on path. CORS preflight requests
+// are answered first. HEAD falls back to the GET handler and OPTIONS is answered automatically
+// unless the route opts out. Any other method that is not allowed goes to HTTPHandle405
+// with the Allow header already set.
+func (s *Server[PayloadType]) methodHandler(path *Path[PayloadType], request *HTTPRequest, response *HTTPResponse) HandlerFunc[PayloadType] {
+	if path.cors != nil && isPreflight(request.HTTP) {
+		return corsPreflight(path.cors, path)
+	}
+
+	method := request.Method()
+	switch {
+	case path.IsMethodAllowed(method):
+	case method == string(HEAD) && path.AllowsAutoHead():
+		method = string(GET)
+	case method == string(OPTIONS) && path.AllowsAutoOptions():
+		return s.answerOptions(path)
+	default:
+		response.Writer.Header().Set("Allow", path.Allow())
+		return s.HTTPHandle405
+	}
+
+	if handler := path.Handler(method); handler != nil {
+		return handler
+	}
+	return s.HTTPHandler
+}
+
+// answerOptions replies to an OPTIONS request with the methods path allows.
+func (s *Server[PayloadType]) answerOptions(path *Path[PayloadType]) HandlerFunc[PayloadType] {
+	return func(request *HTTPRequest, response *HTTPResponse, payload PayloadType) {
+		response.Writer.Header().Set("Allow", path.Allow())
+		response.Status(http.StatusNoContent)
+	}
+}
+
+// recoverPanic turns a panic in any handler or middleware into a log record,
+// a call to HTTPHandle500 and a 500 response when nothing was written yet.
+// http.ErrAbortHandler is passed on, as net/http uses it to abort a response silently.
+func (s *Server[PayloadType]) recoverPanic(request *HTTPRequest, response *HTTPResponse, payload PayloadType) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if recovered == http.ErrAbortHandler {
+		panic(recovered)
+	}
+
+	stack := debug.Stack()
+	s.logger().Error("panic recovered",
+		slog.String("method", request.Method()),
+		slog.String("path", request.HTTP.URL.Path),
+		slog.Any("panic", recovered),
+		slog.String("stack", string(stack)),
+	)
+
+	if s.HTTPHandle500 != nil {
+		s.HTTPHandle500(request, response, payload, recovered, stack)
+	}
+	if !response.Written() {
+		response.Status(http.StatusInternalServerError)
+	}
+}
+
+func (s *Server[PayloadType]) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+// legacyMatch finds the endpoint by running the regex of every parameterized route,
+// split across RegexOptions.ParallelSearchCount goroutines.
+func (s *Server[PayloadType]) legacyMatch(r *http.Request) (*Path[PayloadType], map[string]string) {
+	var (
+		path   *Path[PayloadType]
+		params = map[string]string{}
+		once   sync.Once
+	)
+
+	for perfix, v := range s.Paths.Regex {
+
+		if p := r.URL.Path; len(p) == 0 || p[len(p)-1] != '/' {
+			r.URL.Path += "/"
+		}
+
+		if !strings.HasPrefix(r.URL.Path, perfix) {
+			continue
+		}
+
+		var wg sync.WaitGroup
+		ctx, cancel := context.WithCancel(r.Context())
+		for i := 0; i < v.SplitedListLen; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for i2 := 0; i2 < len(v.SplitedList[i]); i2++ {
+					// Eğer birisi sonucu bulmuşsa, çık
+					select {
+					case <-ctx.Done():
+						return
+					default:
+						if !v.SplitedList[i][i2].regexName.MatchString(r.URL.Path) {
+							continue
+						}
+						cancel()
+
+						// Only the first matching goroutine may write the result.
+						once.Do(func() {
 							perfixedPath := r.URL.Path[len(perfix):]
 							if p := perfixedPath; len(p) == 0 || p[len(p)-1] != '/' {
 								perfixedPath += "/"
@@ -220,35 +677,17 @@ func (s *Server[PayloadType]) mainHandler(w http.ResponseWriter, r *http.Request
 							}
 
 							path = v.SplitedList[i][i2]
-							return
-						}
+						})
+						return
 					}
-				}(i)
-			}
-
-			wg.Wait()
-			break
+				}
+			}(i)
 		}
-	}
 
-	request := HTTPRequest{HTTP: r, Params: params}
-	response := HTTPResponse{Writer: w}
-
-	if path != nil {
-		switch request.IsWebSocketConnection() {
-		case true:
-			s.WebSocketHandler(&request, &response, path.Payload, path.WebSocket.Upgrader)
-			return
-		default:
-			switch path.IsMethodAllowed(request.Method()) {
-			case true:
-				s.HTTPHandler(&request, &response, path.Payload)
-			default:
-				s.HTTPHandle405(&request, &response, path.Payload)
-			}
-			return
-		}
+		wg.Wait()
+		cancel()
+		break
 	}
-	var zeroValue PayloadType
-	s.HTTPHandle404(&request, &response, zeroValue)
+
+	return path, params
 }
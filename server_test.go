@@ -0,0 +1,307 @@
+package streamgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoHeadAndOptions(t *testing.T) {
+	s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		response.Writer.Header().Set("X-Route", payload)
+		if payload == "copy" {
+			io.Copy(response.Writer, strings.NewReader("copied body"))
+			return
+		}
+		response.HTML("user " + request.Params["id"])
+	}, []Path[string]{
+		{Name: "/users/:id:", Payload: "users"},
+		{Name: "/copy", Payload: "copy"},
+		{Name: "/nohead", Payload: "nohead", HTTP: HTTP{DisableAutoHead: true}},
+	})
+
+	tests := []struct {
+		method string
+		url    string
+		status int
+		body   string
+		allow  string
+	}{
+		{"GET", "/users/5", 200, "user 5", ""},
+		{"HEAD", "/users/5", 200, "", ""},
+		{"HEAD", "/copy", 200, "", ""},
+		{"OPTIONS", "/users/5", 204, "", "GET, HEAD, OPTIONS"},
+		{"POST", "/users/5", 405, "405", "GET, HEAD, OPTIONS"},
+		{"HEAD", "/nohead", 405, "", "GET, OPTIONS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			w := do(s, httptest.NewRequest(tt.method, tt.url, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestHeadKeepsHeadersAndCountsBody(t *testing.T) {
+	var size int64
+	s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		response.Writer.Header().Set("X-Route", "users")
+		response.HTML("user " + request.Params["id"])
+		size = response.BytesWritten()
+	}, []Path[string]{{Name: "/users/:id:"}})
+
+	w := do(s, httptest.NewRequest("HEAD", "/users/5", nil))
+
+	if w.Header().Get("X-Route") != "users" || w.Header().Get("Content-Type") == "" {
+		t.Errorf("headers = %v, want those of the GET response", w.Header())
+	}
+	if size != int64(len("user 5")) {
+		t.Errorf("BytesWritten = %d, want %d", size, len("user 5"))
+	}
+	if got := w.Header().Get("Content-Length"); got != "6" {
+		t.Errorf("Content-Length = %q, want 6", got)
+	}
+}
+
+func TestHeadMatchesGetHeaders(t *testing.T) {
+	s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		switch payload {
+		case "sniffed":
+			response.Writer.Write([]byte("<html><body>sniffed</body></html>"))
+		case "copied":
+			io.Copy(response.Writer, strings.NewReader("copied body"))
+		default:
+			response.HTML("user " + request.Params["id"])
+		}
+	}, []Path[string]{
+		{Name: "/users/:id:"},
+		{Name: "/sniffed", Payload: "sniffed"},
+		{Name: "/copied", Payload: "copied"},
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	fetch := func(method, url string) (http.Header, string) {
+		req, err := http.NewRequest(method, ts.URL+url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		resp.Header.Del("Date")
+		return resp.Header, string(body)
+	}
+
+	for _, url := range []string{"/users/5", "/sniffed", "/copied"} {
+		getHeader, _ := fetch("GET", url)
+		headHeader, body := fetch("HEAD", url)
+		if body != "" {
+			t.Errorf("HEAD %s body = %q", url, body)
+		}
+		if !reflect.DeepEqual(headHeader, getHeader) {
+			t.Errorf("HEAD %s headers = %v, want those of GET %v", url, headHeader, getHeader)
+		}
+	}
+}
+
+func TestBuildPathsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []Path[string]
+		want  error
+		msg   string
+	}{
+		{"duplicate static", []Path[string]{{Name: "/a"}, {Name: "/a/"}}, ErrDuplicateRoute, "duplicate route: /a/ conflicts with /a/"},
+		{"duplicate inside include", []Path[string]{
+			{Name: "/api/users"},
+			{Name: "/api", Include: []Path[string]{{Name: "/users"}}},
+		}, ErrDuplicateRoute, "duplicate route: /api/users/ conflicts with /api/users/"},
+		{"invalid name", []Path[string]{{Name: "/f/*rest/more"}}, ErrCatchAllNotLast, "invalid URL path /f/*rest/more/: catch-all parameter must be the last segment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer[string](NewRegexOptions(1))
+			err := s.BuildPaths(tt.paths, "")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("BuildPaths = %v, want %v", err, tt.want)
+			}
+			if tt.msg != "" && err.Error() != tt.msg {
+				t.Errorf("error = %q, want %q", err, tt.msg)
+			}
+		})
+	}
+}
+
+func TestListenErrors(t *testing.T) {
+	s := newTestServer(t, answerOK, []Path[string]{{Name: "/"}})
+
+	if err := s.Listen(context.Background(), "", ""); !errors.Is(err, ErrNoListener) {
+		t.Errorf("Listen without addresses = %v, want %v", err, ErrNoListener)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// A busy address is reported to the caller instead of ending the process.
+	done := make(chan error, 1)
+	go func() { done <- s.Listen(context.Background(), ln.Addr().String(), "") }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Listen on a busy address returned nil")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen on a busy address did not return")
+	}
+}
+
+func TestListenAllKeepsConfigs(t *testing.T) {
+	s := newTestServer(t, answerOK, []Path[string]{{Name: "/"}})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listeners := []ListenerConfig{{Listener: ln}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAll(ctx, listeners...) }()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("ListenAll: %v", err)
+	}
+
+	// The defaults filled in by ListenAll do not leak into the caller's slice.
+	if got := listeners[0]; got.Name != "" || got.Network != "" {
+		t.Errorf("caller's config = %+v, want it unchanged", got)
+	}
+}
+
+func TestServerAsHandler(t *testing.T) {
+	s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		response.HTML(payload + " " + request.Params["id"] + " on " + request.Listener())
+	}, []Path[string]{{Name: "/users/:id:", Payload: "user"}})
+
+	// Mounted below a prefix of another mux and served by an http.Server it did not start.
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", s))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/users/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(body) != "user 7 on " {
+		t.Errorf("GET /api/users/7 = %d %q, want 200 %q", resp.StatusCode, body, "user 7 on ")
+	}
+
+	resp, err = http.Get(ts.URL + "/api/nowhere")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 404 {
+		t.Errorf("GET /api/nowhere = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	var log bytes.Buffer
+	var got500 struct {
+		payload   string
+		recovered any
+		stack     string
+	}
+	s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		switch payload {
+		case "after write":
+			response.HTML("partial")
+			panic("late")
+		case "abort":
+			panic(http.ErrAbortHandler)
+		default:
+			panic("boom")
+		}
+	}, []Path[string]{
+		{Name: "/panic", Payload: "panic"},
+		{Name: "/after", Payload: "after write"},
+		{Name: "/abort", Payload: "abort"},
+	}, func(s *Server[string]) {
+		s.Logger = slog.New(slog.NewTextHandler(&log, nil))
+		s.HTTPHandle404 = func(request *HTTPRequest, response *HTTPResponse, payload string) {
+			panic("not found")
+		}
+	})
+
+	// Without HTTPHandle500 the client gets a bare 500 and the panic is logged with its stack.
+	if w := do(s, httptest.NewRequest("GET", "/panic", nil)); w.Code != 500 {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if out := log.String(); !strings.Contains(out, "panic recovered") || !strings.Contains(out, "boom") || !strings.Contains(out, "path=/panic") {
+		t.Errorf("log = %q, want the recovered panic", out)
+	}
+	if w := do(s, httptest.NewRequest("GET", "/missing", nil)); w.Code != 500 {
+		t.Errorf("status after a panic in HTTPHandle404 = %d, want 500", w.Code)
+	}
+
+	// A response already on its way keeps its status.
+	if w := do(s, httptest.NewRequest("GET", "/after", nil)); w.Code != 200 || w.Body.String() != "partial" {
+		t.Errorf("panic after writing = %d %q, want 200 partial", w.Code, w.Body.String())
+	}
+
+	s.HTTPHandle500 = func(request *HTTPRequest, response *HTTPResponse, payload string, recovered any, stack []byte) {
+		got500.payload, got500.recovered, got500.stack = payload, recovered, string(stack)
+		response.Status(http.StatusServiceUnavailable)
+		response.HTML("sorry")
+	}
+	if w := do(s, httptest.NewRequest("GET", "/panic", nil)); w.Code != 503 || w.Body.String() != "sorry" {
+		t.Errorf("HTTPHandle500 response = %d %q, want 503 sorry", w.Code, w.Body.String())
+	}
+	if got500.payload != "panic" || got500.recovered != "boom" || !strings.Contains(got500.stack, "TestRecoverPanic") {
+		t.Errorf("HTTPHandle500 got payload %q, recovered %v, stack %q", got500.payload, got500.recovered, got500.stack)
+	}
+
+	// net/http relies on http.ErrAbortHandler reaching it to abort the response silently.
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != http.ErrAbortHandler {
+				t.Errorf("recovered %v, want http.ErrAbortHandler passed on", recovered)
+			}
+		}()
+		do(s, httptest.NewRequest("GET", "/abort", nil))
+	}()
+}
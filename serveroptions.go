@@ -0,0 +1,49 @@
+package streamgo
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ServerOptions configures every http.Server created by Listen.
+// Zero values keep the net/http defaults.
+type ServerOptions struct {
+	// ReadTimeout is the maximum duration for reading the entire request, including the body.
+	ReadTimeout time.Duration
+
+	// ReadHeaderTimeout is the maximum duration for reading the request headers.
+	// Public listeners should set it to protect against slowloris-style clients.
+	ReadHeaderTimeout time.Duration
+
+	// WriteTimeout is the maximum duration before timing out writes of the response.
+	WriteTimeout time.Duration
+
+	// IdleTimeout is the maximum time to wait for the next request on a keep-alive connection.
+	IdleTimeout time.Duration
+
+	// MaxHeaderBytes limits the size of the request headers.
+	MaxHeaderBytes int
+
+	// ErrorLog receives errors from accepting connections and from handlers.
+	ErrorLog *log.Logger
+
+	// ConnState is called when a client connection changes state.
+	ConnState func(net.Conn, http.ConnState)
+}
+
+// newHTTPServer creates an http.Server for handler configured with these options.
+func (o *ServerOptions) newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       o.ReadTimeout,
+		ReadHeaderTimeout: o.ReadHeaderTimeout,
+		WriteTimeout:      o.WriteTimeout,
+		IdleTimeout:       o.IdleTimeout,
+		MaxHeaderBytes:    o.MaxHeaderBytes,
+		ErrorLog:          o.ErrorLog,
+		ConnState:         o.ConnState,
+	}
+}
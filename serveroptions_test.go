@@ -0,0 +1,104 @@
+package streamgo
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPServer(t *testing.T) {
+	handler := http.NewServeMux()
+	opts := ServerOptions{
+		ReadTimeout:       1 * time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+		WriteTimeout:      3 * time.Second,
+		IdleTimeout:       4 * time.Second,
+		MaxHeaderBytes:    5,
+		ErrorLog:          log.New(io.Discard, "", 0),
+		ConnState:         func(net.Conn, http.ConnState) {},
+	}
+
+	srv := opts.newHTTPServer("127.0.0.1:80", handler)
+	if srv.Addr != "127.0.0.1:80" || srv.Handler != handler {
+		t.Errorf("Addr, Handler = %q, %v", srv.Addr, srv.Handler)
+	}
+	got := ServerOptions{
+		ReadTimeout:       srv.ReadTimeout,
+		ReadHeaderTimeout: srv.ReadHeaderTimeout,
+		WriteTimeout:      srv.WriteTimeout,
+		IdleTimeout:       srv.IdleTimeout,
+		MaxHeaderBytes:    srv.MaxHeaderBytes,
+		ErrorLog:          srv.ErrorLog,
+	}
+	want := opts
+	want.ConnState = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("server options = %+v, want %+v", got, want)
+	}
+	if srv.ConnState == nil {
+		t.Error("ConnState was not copied")
+	}
+}
+
+func TestListenAllServerOptions(t *testing.T) {
+	var mu sync.Mutex
+	states := map[http.ConnState]int{}
+
+	s := newTestServer(t, answerOK, []Path[string]{{Name: "/"}})
+	s.Options = ServerOptions{
+		ReadHeaderTimeout: 50 * time.Millisecond,
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			mu.Lock()
+			states[state]++
+			mu.Unlock()
+		},
+	}
+	addrs, stop := listenLoopback(t, s,
+		ListenerConfig{Name: "default"},
+		ListenerConfig{Name: "override", Options: &ServerOptions{ReadHeaderTimeout: 10 * time.Second}},
+	)
+
+	// partial sends an incomplete request and reports whether the server closes the
+	// connection within wait.
+	partial := func(addr string, wait time.Duration) bool {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n"); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(wait))
+		_, err = conn.Read(make([]byte, 1024))
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return false
+		}
+		return true
+	}
+
+	if !partial(addrs[0], 2*time.Second) {
+		t.Error("Server.Options.ReadHeaderTimeout was not applied")
+	}
+	if partial(addrs[1], 300*time.Millisecond) {
+		t.Error("ListenerConfig.Options did not override Server.Options")
+	}
+
+	if err := stop(); err != nil {
+		t.Fatalf("ListenAll: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if states[http.StateNew] == 0 || states[http.StateClosed] == 0 {
+		t.Errorf("ConnState calls = %v, want new and closed connections", states)
+	}
+	if s.TCPServer == nil || s.TCPServer.ReadHeaderTimeout != 50*time.Millisecond {
+		t.Errorf("TCPServer = %+v, want the first listener's server", s.TCPServer)
+	}
+}
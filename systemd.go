@@ -0,0 +1,218 @@
+package streamgo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFDsStart is the first file descriptor passed by socket activation.
+const listenFDsStart = 3
+
+var ErrNoActiveListeners = errors.New("no active listeners to hand off")
+
+// inheritedListener is a listener received through LISTEN_FDS.
+type inheritedListener struct {
+	name     string
+	listener net.Listener
+	claimed  bool
+}
+
+var (
+	inheritedOnce sync.Once
+	inheritedMu   sync.Mutex
+	inherited     []*inheritedListener
+	inheritedErr  error
+)
+
+// InheritedListeners returns the listeners passed to the process through systemd socket
+// activation (LISTEN_FDS and LISTEN_FDNAMES) or by Server.Handoff, keyed by name.
+// When LISTEN_PID is set it must match the current process. The environment variables
+// are read once and then removed, so child processes do not inherit them.
+// ListenAll uses these listeners for configs with a matching Name instead of binding.
+func InheritedListeners() (map[string]net.Listener, error) {
+	loadInheritedListeners()
+
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+
+	listeners := make(map[string]net.Listener, len(inherited))
+	for _, l := range inherited {
+		if _, ok := listeners[l.name]; !ok {
+			listeners[l.name] = l.listener
+		}
+	}
+	return listeners, inheritedErr
+}
+
+func loadInheritedListeners() {
+	inheritedOnce.Do(func() {
+		inherited, inheritedErr = parseListenFDs()
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+}
+
+func parseListenFDs() ([]*inheritedListener, error) {
+	fds := os.Getenv("LISTEN_FDS")
+	if fds == "" {
+		return nil, nil
+	}
+
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+
+	var names []string
+	if v := os.Getenv("LISTEN_FDNAMES"); v != "" {
+		names = strings.Split(v, ":")
+	}
+
+	listeners := make([]*inheritedListener, 0, count)
+	for i := 0; i < count; i++ {
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		listener, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			// Nobody can claim the listeners of a failed parse, so close them here.
+			for _, l := range listeners {
+				l.listener.Close()
+			}
+			return nil, fmt.Errorf("inherit listener %v: %w", name, err)
+		}
+		listeners = append(listeners, &inheritedListener{name: name, listener: listener})
+	}
+	return listeners, nil
+}
+
+// claimInheritedListener hands out an unclaimed inherited listener with the given name.
+func claimInheritedListener(name string) (net.Listener, bool) {
+	loadInheritedListeners()
+
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+
+	for _, l := range inherited {
+		if l.name == name && !l.claimed {
+			l.claimed = true
+			return l.listener, true
+		}
+	}
+	return nil, false
+}
+
+// activeListener is a listener being served by ListenAll.
+type activeListener struct {
+	name     string
+	listener net.Listener
+}
+
+// activeListeners records what ListenAll is serving, so it can be handed off.
+type activeListeners struct {
+	mu        sync.Mutex
+	list      []activeListener
+	handedOff bool
+}
+
+func (a *activeListeners) set(list []activeListener) {
+	a.mu.Lock()
+	a.list = list
+	a.handedOff = false
+	a.mu.Unlock()
+}
+
+// keepSockets reports whether the socket files now belong to a child process.
+func (a *activeListeners) keepSockets() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.handedOff
+}
+
+// Handoff starts a new instance of the running executable with the same arguments,
+// passing it every listener served by ListenAll through LISTEN_FDS and LISTEN_FDNAMES.
+// The child picks them up by name, so no connection is refused during the restart.
+// Afterwards the caller should cancel the Listen context to drain and exit;
+// socket files are then left in place for the child.
+func (s *Server[PayloadType]) Handoff() (*os.Process, error) {
+	if s.active == nil {
+		return nil, ErrNoActiveListeners
+	}
+
+	s.active.mu.Lock()
+	defer s.active.mu.Unlock()
+
+	if len(s.active.list) == 0 {
+		return nil, ErrNoActiveListeners
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*os.File, 0, len(s.active.list))
+	names := make([]string, 0, len(s.active.list))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, a := range s.active.list {
+		fl, ok := a.listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("hand off listener %v: %T has no file descriptor", a.name, a.listener)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return nil, fmt.Errorf("hand off listener %v: %w", a.name, err)
+		}
+		files = append(files, f)
+		names = append(names, a.name)
+	}
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, v := range os.Environ() {
+		if strings.HasPrefix(v, "LISTEN_PID=") || strings.HasPrefix(v, "LISTEN_FDS=") || strings.HasPrefix(v, "LISTEN_FDNAMES=") {
+			continue
+		}
+		env = append(env, v)
+	}
+	env = append(env, "LISTEN_FDS="+strconv.Itoa(len(files)), "LISTEN_FDNAMES="+strings.Join(names, ":"))
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// Closing our copies must not unlink the sockets the child now serves.
+	for _, a := range s.active.list {
+		if ul, ok := a.listener.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	s.active.handedOff = true
+
+	return cmd.Process, nil
+}
@@ -0,0 +1,289 @@
+package streamgo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// childEnv switches the test binary into a child process instead of running the tests:
+// failedParseChild when set to "failed-parse", serveInheritedChild otherwise.
+const childEnv = "STREAMGO_TEST_CHILD"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(childEnv) {
+	case "":
+		os.Exit(m.Run())
+	case "failed-parse":
+		os.Exit(failedParseChild())
+	default:
+		os.Exit(serveInheritedChild())
+	}
+}
+
+// failedParseChild checks that a LISTEN_FDS parse failing on its second descriptor
+// closes the listener it already created from the first one.
+func failedParseChild() int {
+	listeners, err := parseListenFDs()
+	if err == nil || listeners != nil {
+		fmt.Fprintf(os.Stderr, "child: parseListenFDs = %v, %v, want an error\n", listeners, err)
+		return 1
+	}
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(listenFDsStart, &stat); err != syscall.EBADF {
+		fmt.Fprintf(os.Stderr, "child: fstat of the first descriptor = %v, want it closed\n", err)
+		return 1
+	}
+	return 0
+}
+
+// serveInheritedChild serves the listener named "public" it inherited through LISTEN_FDS,
+// answering "child" until /stop is requested.
+func serveInheritedChild() int {
+	listeners, err := InheritedListeners()
+	if err != nil || listeners["public"] == nil {
+		fmt.Fprintf(os.Stderr, "child: inherited %v, %v\n", listeners, err)
+		return 1
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		fmt.Fprintln(os.Stderr, "child: LISTEN_FDS was not unset")
+		return 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewServer[string](NewRegexOptions(1))
+	s.HTTPHandler = func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		response.HTML("child " + request.Listener())
+		if payload == "stop" {
+			cancel()
+		}
+	}
+	if err := s.BuildPaths([]Path[string]{{Name: "/who"}, {Name: "/stop", Payload: "stop"}}, ""); err != nil {
+		fmt.Fprintln(os.Stderr, "child:", err)
+		return 1
+	}
+
+	// The address is never bound: the inherited listener with the same name is used instead.
+	err = s.ListenAll(ctx, ListenerConfig{Name: "public", Network: "tcp", Address: "127.0.0.1:0"})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "child:", err)
+		return 1
+	}
+	return 0
+}
+
+// get requests url until it answers or the deadline passes.
+func get(t *testing.T, url string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp, err := http.Get(url)
+		if err == nil {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return string(body)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET %v: %v", url, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func waitChild(t *testing.T, wait func() error) {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() { done <- wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("child exited: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("child did not exit")
+	}
+}
+
+func TestSocketActivation(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	// Like systemd: the socket is fd 3, described by LISTEN_FDS and LISTEN_FDNAMES.
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), childEnv+"=1", "LISTEN_FDS=1", "LISTEN_FDNAMES=public")
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer cmd.Process.Kill()
+
+	if got := get(t, "http://"+addr+"/who"); got != "child public" {
+		t.Fatalf("GET /who = %q, want %q", got, "child public")
+	}
+	get(t, "http://"+addr+"/stop")
+	waitChild(t, cmd.Wait)
+}
+
+func TestSocketActivationOtherPID(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := parseListenFDs()
+	if err != nil || len(listeners) != 0 {
+		t.Fatalf("parseListenFDs = %v, %v, want nothing for another process", listeners, err)
+	}
+}
+
+func TestSocketActivationInvalidCount(t *testing.T) {
+	t.Setenv("LISTEN_FDS", "x")
+
+	if _, err := parseListenFDs(); err == nil {
+		t.Fatal("parseListenFDs accepted LISTEN_FDS=x")
+	}
+}
+
+func TestSocketActivationClosesOnFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	notSocket, err := os.Open(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notSocket.Close()
+
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), childEnv+"=failed-parse", "LISTEN_FDS=2", "LISTEN_FDNAMES=public:file")
+	cmd.ExtraFiles = []*os.File{f, notSocket}
+	cmd.Stderr = os.Stderr
+	waitChild(t, cmd.Run)
+}
+
+func TestHandoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		response.HTML("parent")
+	}, []Path[string]{{Name: "/who"}})
+
+	if _, err := s.Handoff(); err != ErrNoActiveListeners {
+		t.Fatalf("Handoff before ListenAll = %v, want %v", err, ErrNoActiveListeners)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAll(ctx, ListenerConfig{Name: "public", Listener: ln}) }()
+	if got := get(t, "http://"+addr+"/who"); got != "parent" {
+		t.Fatalf("GET /who = %q, want parent", got)
+	}
+
+	// Handoff re-executes the test binary, which serveInheritedChild takes over in the child.
+	t.Setenv(childEnv, "1")
+	child, err := s.Handoff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer child.Kill()
+
+	cancel()
+	<-done
+
+	// The parent no longer serves, so the answer must come from the child on the same socket.
+	if got := get(t, "http://"+addr+"/who"); got != "child public" {
+		t.Fatalf("GET /who after handoff = %q, want %q", got, "child public")
+	}
+	get(t, "http://"+addr+"/stop")
+	waitChild(t, func() error {
+		state, err := child.Wait()
+		if err == nil && !state.Success() {
+			err = fmt.Errorf("%v", state)
+		}
+		return err
+	})
+}
+
+func TestHandoffKeepsUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "public.sock")
+	s := newTestServer(t, answerOK, []Path[string]{{Name: "/who"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAll(ctx, ListenerConfig{Name: "public", Network: "unix", Address: path}) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	get := func() (string, error) {
+		resp, err := client.Get("http://unix/who")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := get(); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Setenv(childEnv, "1")
+	child, err := s.Handoff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer child.Kill()
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("ListenAll: %v", err)
+	}
+
+	// The parent's shutdown leaves the socket file to the child serving it.
+	if got, err := get(); err != nil || got != "child public" {
+		t.Fatalf("GET /who after handoff = %q, %v, want %q", got, err, "child public")
+	}
+	client.Get("http://unix/stop")
+	waitChild(t, func() error {
+		_, err := child.Wait()
+		return err
+	})
+}
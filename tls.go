@@ -0,0 +1,193 @@
+package streamgo
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrNoClientCA = errors.New("no certificates found in client CA file")
+
+// TLSOptions configures a TLS listener.
+// Certificates are loaded from CertFile and KeyFile, or taken from Config when no files are set.
+// Setting ClientCAFile enables mutual TLS.
+type TLSOptions struct {
+	// CertFile and KeyFile are PEM encoded files holding the server certificate chain and its key.
+	CertFile string
+	KeyFile  string
+
+	// Config is the base TLS configuration. It is cloned and never modified.
+	Config *tls.Config
+
+	// ClientCAFile is a PEM bundle of the CAs that client certificates are verified against.
+	ClientCAFile string
+
+	// ClientAuth is the client certificate policy used with ClientCAFile.
+	// It defaults to tls.RequireAndVerifyClientCert.
+	ClientAuth tls.ClientAuthType
+
+	// ReloadInterval is how often the files are checked for changes.
+	// Zero checks every 10 seconds, a negative value disables reloading.
+	ReloadInterval time.Duration
+}
+
+// tlsReloader serves the certificate and client CAs loaded from TLSOptions
+// and replaces them when the files change on disk.
+type tlsReloader struct {
+	opts *TLSOptions
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+func newTLSReloader(opts *TLSOptions) (*tlsReloader, error) {
+	r := &tlsReloader{opts: opts}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads the certificate and CA files.
+func (r *tlsReloader) load() error {
+	modTimes := map[string]time.Time{}
+	for _, name := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.ClientCAFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		modTimes[name] = info.ModTime()
+	}
+
+	// Remember the files even if they turn out to be invalid, so a broken
+	// update is reported once and retried only after the next change.
+	r.mu.Lock()
+	r.modTimes = modTimes
+	r.mu.Unlock()
+
+	var cert *tls.Certificate
+	if r.opts.CertFile != "" || r.opts.KeyFile != "" {
+		c, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+		if err != nil {
+			return fmt.Errorf("load certificate %v: %w", r.opts.CertFile, err)
+		}
+		cert = &c
+	}
+
+	var clientCAs *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%w: %v", ErrNoClientCA, r.opts.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = cert
+	r.clientCAs = clientCAs
+	r.mu.Unlock()
+	return nil
+}
+
+// changed reports whether any of the files was modified since the last load.
+func (r *tlsReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for name, modTime := range r.modTimes {
+		info, err := os.Stat(name)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch reloads the files whenever they change until ctx is done.
+// A failed reload keeps the previous certificates.
+func (r *tlsReloader) watch(ctx context.Context) {
+	interval := r.opts.ReloadInterval
+	if interval < 0 || len(r.modTimes) == 0 {
+		return
+	}
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.load(); err != nil {
+				log.Printf("Error reloading TLS certificates: %v", err)
+			}
+		}
+	}
+}
+
+func (r *tlsReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// config builds the tls.Config for the listener.
+func (r *tlsReloader) config() *tls.Config {
+	var base *tls.Config
+	if r.opts.Config != nil {
+		base = r.opts.Config.Clone()
+	} else {
+		base = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if len(base.NextProtos) == 0 {
+		base.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	if r.cert != nil {
+		base.Certificates = nil
+		base.GetCertificate = r.getCertificate
+	}
+
+	if r.clientCAs == nil {
+		return base
+	}
+
+	base.ClientAuth = r.opts.ClientAuth
+	if base.ClientAuth == tls.NoClientCert {
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	// Every handshake gets the client CAs that are current at that moment.
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.ClientCAs = r.clientCAs
+		return cfg, nil
+	}
+	return base
+}
@@ -0,0 +1,212 @@
+package streamgo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and its key, signed by parent or self-signed when parent is nil.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write stores the certificate and key as PEM files and moves their modification time
+// forward, so reloads notice the change on file systems with coarse timestamps.
+func (c *testCert) write(t *testing.T, certFile, keyFile string, age time.Duration) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, certFile, "CERTIFICATE", c.der, age)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER, age)
+}
+
+func writePEM(t *testing.T, name, kind string, der []byte, age time.Duration) {
+	t.Helper()
+
+	if err := os.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestTLSReloaderLoad(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	first := newTestCert(t, "first", nil)
+	first.write(t, certFile, keyFile, time.Hour)
+	r, err := newTLSReloader(&TLSOptions{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.changed() {
+		t.Error("changed() = true right after loading")
+	}
+
+	second := newTestCert(t, "second", nil)
+	second.write(t, certFile, keyFile, 0)
+	if !r.changed() {
+		t.Fatal("changed() = false after the files were replaced")
+	}
+	if err := r.load(); err != nil {
+		t.Fatal(err)
+	}
+	if cert, _ := r.getCertificate(nil); cert.Leaf == nil || cert.Leaf.Subject.CommonName != "second" {
+		t.Errorf("certificate after reload = %v, want second", cert.Leaf)
+	}
+
+	// A broken update keeps the previous certificate and is not retried until the next change.
+	if err := os.WriteFile(certFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.load(); err == nil {
+		t.Fatal("load of a broken certificate succeeded")
+	}
+	if cert, _ := r.getCertificate(nil); cert.Leaf.Subject.CommonName != "second" {
+		t.Errorf("certificate after a failed reload = %v, want second", cert.Leaf.Subject.CommonName)
+	}
+	if r.changed() {
+		t.Error("changed() = true after a failed reload of the same files")
+	}
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, []byte("no certificates"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	first.write(t, certFile, keyFile, 0)
+	if _, err := newTLSReloader(&TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}); !errors.Is(err, ErrNoClientCA) {
+		t.Errorf("newTLSReloader with an empty CA bundle = %v, want %v", err, ErrNoClientCA)
+	}
+}
+
+func TestListenAllMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+
+	ca := newTestCert(t, "ca", nil)
+	writePEM(t, caFile, "CERTIFICATE", ca.der, 0)
+	newTestCert(t, "server-1", ca).write(t, certFile, keyFile, time.Hour)
+	client := newTestCert(t, "client", ca)
+	stranger := newTestCert(t, "stranger", newTestCert(t, "other-ca", nil))
+
+	s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		response.HTML(request.HTTP.TLS.PeerCertificates[0].Subject.CommonName)
+	}, []Path[string]{{Name: "/"}})
+	addrs, stop := listenLoopback(t, s, ListenerConfig{
+		Name: "tls",
+		TLS: &TLSOptions{
+			CertFile:       certFile,
+			KeyFile:        keyFile,
+			ClientCAFile:   caFile,
+			ReloadInterval: 10 * time.Millisecond,
+		},
+	})
+	defer stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(clientCert *testCert) (string, string, error) {
+		cfg := &tls.Config{RootCAs: roots}
+		if clientCert != nil {
+			cfg.Certificates = []tls.Certificate{clientCert.tlsCertificate()}
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+		res, err := c.Get("https://" + addrs[0] + "/")
+		if err != nil {
+			return "", "", err
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return string(body), res.TLS.PeerCertificates[0].Subject.CommonName, err
+	}
+
+	if body, server, err := get(client); err != nil || body != "client" || server != "server-1" {
+		t.Fatalf("GET with a client certificate = %q from %q, %v", body, server, err)
+	}
+	if _, _, err := get(nil); err == nil {
+		t.Error("GET without a client certificate succeeded")
+	}
+	if _, _, err := get(stranger); err == nil {
+		t.Error("GET with a certificate from another CA succeeded")
+	}
+
+	// The listener picks up a replaced certificate without restarting.
+	newTestCert(t, "server-2", ca).write(t, certFile, keyFile, 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, server, err := get(client)
+		if err == nil && server == "server-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("certificate was not reloaded: serving %q, %v", server, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,249 @@
+package streamgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent header")
+
+const (
+	traceparentHeader = "Traceparent"
+	tracestateHeader  = "Tracestate"
+
+	// maxTracestateLength is the size limit of tracestate from the W3C Trace Context specification.
+	maxTracestateLength = 512
+)
+
+// TraceFlagSampled is the trace flag marking a trace as sampled.
+const TraceFlagSampled byte = 0x01
+
+// TraceID identifies a trace across services.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// IsValid reports whether the ID is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is the part of a span that is propagated between services
+// through the traceparent and tracestate headers.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Sampled reports whether the sampled flag is set.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&TraceFlagSampled != 0
+}
+
+// Traceparent formats the context as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// Inject sets the traceparent and tracestate headers of an outgoing request.
+func (sc SpanContext) Inject(header http.Header) {
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(traceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(tracestateHeader, sc.TraceState)
+	}
+}
+
+// ParseTraceparent parses a traceparent header value.
+// Versions other than 00 are accepted as long as their first four fields are valid.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	fields := strings.Split(strings.TrimSpace(value), "-")
+	if len(fields) < 4 || len(fields[0]) != 2 || len(fields[1]) != 32 || len(fields[2]) != 16 || len(fields[3]) != 2 {
+		return sc, ErrInvalidTraceparent
+	}
+	if fields[0] == "ff" || (fields[0] == "00" && len(fields) != 4) {
+		return sc, ErrInvalidTraceparent
+	}
+	for _, f := range fields[:4] {
+		if strings.ToLower(f) != f {
+			return sc, ErrInvalidTraceparent
+		}
+	}
+
+	var version, flags [1]byte
+	if _, err := hex.Decode(version[:], []byte(fields[0])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(fields[1])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(fields[2])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(flags[:], []byte(fields[3])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// Span records one request handled by the server.
+// Handlers may add attributes through HTTPRequest.Span; a span is not safe for concurrent use.
+type Span struct {
+	// Name is the method followed by the route pattern, such as "GET /users/:id:/",
+	// or "GET unmatched" when no route matched.
+	Name string
+
+	// Context identifies this span. Its TraceState is the one received from the client.
+	Context SpanContext
+
+	// Parent is the context received in the traceparent header, invalid for a new trace.
+	Parent SpanContext
+
+	Start time.Time
+	End   time.Time
+
+	Method string
+	Route  string
+	Status int
+
+	// Attributes holds additional values set with SetAttribute.
+	Attributes map[string]string
+}
+
+// SetAttribute records a value on the span.
+func (span *Span) SetAttribute(key, value string) {
+	if span.Attributes == nil {
+		span.Attributes = map[string]string{}
+	}
+	span.Attributes[key] = value
+}
+
+// SpanExporter receives finished, sampled spans.
+// ExportSpan is called on the request goroutine and should not block;
+// implementations bridging to a tracing backend usually queue the span.
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// InMemoryExporter keeps exported spans in memory, mainly for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+func (e *InMemoryExporter) ExportSpan(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, *span)
+}
+
+// Spans returns a copy of the exported spans in export order.
+func (e *InMemoryExporter) Spans() []Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Span(nil), e.spans...)
+}
+
+// Reset discards the exported spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+// TracingOptions configures W3C Trace Context handling of a Server.
+// A span is created for every request and the response carries its traceparent.
+// Requests that match no route share the route name "unmatched".
+type TracingOptions struct {
+	// Exporter receives the sampled spans. When nil, contexts are still propagated.
+	Exporter SpanExporter
+
+	// SampleRate is the fraction of new traces that are sampled, between 0 and 1.
+	// Zero samples every trace. Traces started by a client keep the client's decision.
+	SampleRate float64
+}
+
+// startSpan creates the span of a request to route, continuing the trace of r when it carries one.
+func (o *TracingOptions) startSpan(r *http.Request, route string) *Span {
+	span := &Span{
+		Name:   r.Method + " " + route,
+		Start:  time.Now(),
+		Method: r.Method,
+		Route:  route,
+	}
+
+	if parent, err := ParseTraceparent(r.Header.Get(traceparentHeader)); err == nil {
+		span.Parent = parent
+		span.Context.TraceID = parent.TraceID
+		span.Context.Flags = parent.Flags
+		if state := r.Header.Get(tracestateHeader); len(state) <= maxTracestateLength {
+			span.Context.TraceState = state
+		}
+	} else {
+		_, _ = rand.Read(span.Context.TraceID[:])
+		if o.SampleRate <= 0 || o.SampleRate >= 1 || mathrand.Float64() < o.SampleRate {
+			span.Context.Flags = TraceFlagSampled
+		}
+	}
+	for !span.Context.SpanID.IsValid() {
+		_, _ = rand.Read(span.Context.SpanID[:])
+	}
+	return span
+}
+
+// endSpan finishes span with the response status and exports it when sampled.
+func (o *TracingOptions) endSpan(span *Span, response *HTTPResponse) {
+	span.End = time.Now()
+	span.Status = response.StatusCode()
+	if span.Status == 0 {
+		span.Status = http.StatusOK
+	}
+	if o.Exporter != nil && span.Context.Sampled() {
+		o.Exporter.ExportSpan(span)
+	}
+}
+
+// spanKey is the context key under which the request span is stored.
+type spanKey struct{}
+
+// SpanFromContext returns the span of the request ctx belongs to.
+func SpanFromContext(ctx context.Context) (*Span, bool) {
+	span, ok := ctx.Value(spanKey{}).(*Span)
+	return span, ok
+}
+
+// Span returns the span of the request, or nil when tracing is disabled.
+// Its Context can be injected into outgoing requests to continue the trace.
+func (r *HTTPRequest) Span() *Span {
+	span, _ := SpanFromContext(r.HTTP.Context())
+	return span
+}
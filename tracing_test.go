@@ -0,0 +1,119 @@
+package streamgo
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func newTracingServer(t *testing.T) (*Server[string], *InMemoryExporter) {
+	t.Helper()
+
+	exporter := &InMemoryExporter{}
+	s := newTestServer(t, func(request *HTTPRequest, response *HTTPResponse, payload string) {
+		if payload == "panic" {
+			panic("boom")
+		}
+		request.Span().SetAttribute("user", request.Params["id"])
+		response.HTML("ok")
+	}, []Path[string]{{Name: "/users/:id:"}, {Name: "/panic", Payload: "panic"}})
+	s.Tracing = &TracingOptions{Exporter: exporter}
+	return s, exporter
+}
+
+func TestTracingSpans(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		name   string
+		url    string
+		parent string
+		span   string
+		status int
+	}{
+		{"matched new trace", "/users/5", "", "GET /users/:id:/", 200},
+		{"matched continued trace", "/users/5", parent, "GET /users/:id:/", 200},
+		{"unmatched new trace", "/nowhere", "", "GET unmatched", 404},
+		{"unmatched continued trace", "/nowhere", parent, "GET unmatched", 404},
+		{"recovered panic", "/panic", "", "GET /panic/", 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, exporter := newTracingServer(t)
+			r := httptest.NewRequest("GET", tt.url, nil)
+			if tt.parent != "" {
+				r.Header.Set("Traceparent", tt.parent)
+				r.Header.Set("Tracestate", "vendor=value")
+			}
+			w := do(s, r)
+
+			spans := exporter.Spans()
+			if len(spans) != 1 {
+				t.Fatalf("exported %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name != tt.span || span.Status != tt.status {
+				t.Errorf("span = %q %d, want %q %d", span.Name, span.Status, tt.span, tt.status)
+			}
+
+			got, err := ParseTraceparent(w.Header().Get("Traceparent"))
+			if err != nil {
+				t.Fatalf("response traceparent: %v", err)
+			}
+			if got != (SpanContext{TraceID: span.Context.TraceID, SpanID: span.Context.SpanID, Flags: span.Context.Flags}) {
+				t.Errorf("response traceparent = %v, want the span context %v", got, span.Context)
+			}
+
+			if tt.parent == "" {
+				if span.Parent.IsValid() {
+					t.Errorf("new trace has parent %v", span.Parent)
+				}
+				return
+			}
+			want, _ := ParseTraceparent(tt.parent)
+			if span.Parent != want || span.Context.TraceID != want.TraceID || span.Context.SpanID == want.SpanID {
+				t.Errorf("span context %v with parent %v does not continue %v", span.Context, span.Parent, want)
+			}
+			if w.Header().Get("Tracestate") != "vendor=value" {
+				t.Errorf("Tracestate = %q, want it echoed", w.Header().Get("Tracestate"))
+			}
+		})
+	}
+}
+
+func TestTracingSpanAttributes(t *testing.T) {
+	s, exporter := newTracingServer(t)
+	serve(s, "GET", "/users/5")
+
+	if spans := exporter.Spans(); len(spans) != 1 || spans[0].Attributes["user"] != "5" {
+		t.Errorf("spans = %+v, want one with user=5", spans)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		sc, err := ParseTraceparent(tt.value)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseTraceparent(%q) = %v, %v, want valid %v", tt.value, sc, err, tt.valid)
+			continue
+		}
+		if tt.valid && tt.value[:2] == "00" && sc.Traceparent() != tt.value {
+			t.Errorf("Traceparent() = %q, want %q", sc.Traceparent(), tt.value)
+		}
+	}
+}
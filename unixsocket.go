@@ -0,0 +1,138 @@
+package streamgo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotSocket   = errors.New("existing file is not a socket")
+	ErrSocketInUse = errors.New("socket is already in use")
+)
+
+// UnixSocketOptions configures the socket file of a unix listener.
+// Addresses starting with '@' are Linux abstract-namespace sockets, which have no file
+// and ignore these options.
+type UnixSocketOptions struct {
+	// Mode is the permission of the socket file. It defaults to 0666.
+	Mode os.FileMode
+
+	// User and Group own the socket file. Both accept a name or a numeric id
+	// and are left unchanged when empty.
+	User  string
+	Group string
+}
+
+// listenUnix creates a unix socket at path configured with opts, which may be nil.
+// An existing file at path is only removed when it is a socket nobody listens on.
+func listenUnix(path string, opts *UnixSocketOptions) (net.Listener, error) {
+	if opts == nil {
+		opts = &UnixSocketOptions{}
+	}
+
+	if strings.HasPrefix(path, "@") {
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("create unix socket %v: %w", path, err)
+		}
+		return listener, nil
+	}
+
+	uid, gid, err := opts.owner()
+	if err != nil {
+		return nil, fmt.Errorf("resolve unix socket owner %v: %w", path, err)
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("create unix socket %v: %w", path, err)
+	}
+
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0666
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("set unix socket permissions %v: %w", path, err)
+	}
+
+	if uid != -1 || gid != -1 {
+		if err := os.Lchown(path, uid, gid); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("set unix socket owner %v: %w", path, err)
+		}
+	}
+	return listener, nil
+}
+
+// removeStaleSocket deletes a socket file left behind by a previous process.
+// It refuses to touch anything that is not a socket or that still accepts connections.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%w: %v", ErrNotSocket, path)
+	}
+
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%w: %v", ErrSocketInUse, path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove existing socket file %v: %w", path, err)
+	}
+	return nil
+}
+
+// owner resolves User and Group to numeric ids, -1 meaning unchanged.
+func (o *UnixSocketOptions) owner() (int, int, error) {
+	uid, gid := -1, -1
+
+	if o.User != "" {
+		id, err := strconv.Atoi(o.User)
+		if err != nil {
+			u, err := user.Lookup(o.User)
+			if err != nil {
+				return 0, 0, err
+			}
+			if id, err = strconv.Atoi(u.Uid); err != nil {
+				return 0, 0, err
+			}
+		}
+		uid = id
+	}
+
+	if o.Group != "" {
+		id, err := strconv.Atoi(o.Group)
+		if err != nil {
+			g, err := user.LookupGroup(o.Group)
+			if err != nil {
+				return 0, 0, err
+			}
+			if id, err = strconv.Atoi(g.Gid); err != nil {
+				return 0, 0, err
+			}
+		}
+		gid = id
+	}
+
+	return uid, gid, nil
+}
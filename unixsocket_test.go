@@ -0,0 +1,125 @@
+package streamgo
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenUnixMode(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		opts *UnixSocketOptions
+		want os.FileMode
+	}{
+		{nil, 0666},
+		{&UnixSocketOptions{Mode: 0600}, 0600},
+		{&UnixSocketOptions{Mode: 0660}, 0660},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, strconv.Itoa(i)+".sock")
+		listener, err := listenUnix(path, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Lstat(path)
+		listener.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != tt.want {
+			t.Errorf("mode with %+v = %v, want socket with %v", tt.opts, info.Mode(), tt.want)
+		}
+	}
+}
+
+func TestListenUnixOwner(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+	// Only root can give the socket away, anyone else can only keep their own ids.
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		uid, gid = 65534, 65534
+	}
+
+	path := filepath.Join(t.TempDir(), "owned.sock")
+	listener, err := listenUnix(path, &UnixSocketOptions{User: strconv.Itoa(uid), Group: strconv.Itoa(gid)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Skip("file owner is not available")
+	}
+	if int(stat.Uid) != uid || int(stat.Gid) != gid {
+		t.Errorf("owner = %d:%d, want %d:%d", stat.Uid, stat.Gid, uid, gid)
+	}
+
+	// Names are resolved to the same ids as numbers.
+	group, err := user.LookupGroupId(current.Gid)
+	if err != nil {
+		t.Skip(err)
+	}
+	gotUID, gotGID, err := (&UnixSocketOptions{User: current.Username, Group: group.Name}).owner()
+	if err != nil || strconv.Itoa(gotUID) != current.Uid || strconv.Itoa(gotGID) != current.Gid {
+		t.Errorf("owner() for %v = %d:%d, %v, want %v:%v", current.Username, gotUID, gotGID, err, current.Uid, current.Gid)
+	}
+	if gotUID, gotGID, err := (&UnixSocketOptions{}).owner(); err != nil || gotUID != -1 || gotGID != -1 {
+		t.Errorf("owner() without user and group = %d:%d, %v, want -1:-1", gotUID, gotGID, err)
+	}
+	if _, err := listenUnix(filepath.Join(t.TempDir(), "x.sock"), &UnixSocketOptions{User: "no-such-user-streamgo"}); err == nil {
+		t.Error("listenUnix with an unknown user succeeded")
+	}
+}
+
+func TestListenUnixExistingFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// A socket left behind by a process that is gone is replaced.
+	stale := filepath.Join(dir, "stale.sock")
+	old, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.(*net.UnixListener).SetUnlinkOnClose(false)
+	old.Close()
+	if _, err := os.Lstat(stale); err != nil {
+		t.Fatalf("stale socket was removed on close: %v", err)
+	}
+	listener, err := listenUnix(stale, nil)
+	if err != nil {
+		t.Fatalf("listenUnix over a stale socket = %v", err)
+	}
+	defer listener.Close()
+
+	// A socket somebody still listens on is left alone.
+	if _, err := listenUnix(stale, nil); !errors.Is(err, ErrSocketInUse) {
+		t.Errorf("listenUnix over a socket in use = %v, want %v", err, ErrSocketInUse)
+	}
+
+	// Anything else is never deleted.
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenUnix(file, nil); !errors.Is(err, ErrNotSocket) {
+		t.Errorf("listenUnix over a regular file = %v, want %v", err, ErrNotSocket)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "data" {
+		t.Errorf("regular file after listenUnix = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,161 @@
+package streamgo
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// webSocketTracker keeps the WebSocket connections upgraded through HTTPResponse.UpgradeWebSocket,
+// so that a graceful shutdown can ask their clients to go away and wait for their handlers.
+type webSocketTracker struct {
+	mu       sync.Mutex
+	conns    map[*websocket.Conn]struct{}
+	closing  bool
+	deadline time.Time
+
+	// handlers counts the running WebSocket handlers. drained is closed when it drops
+	// to zero while wait is blocked on it.
+	handlers int
+	drained  chan struct{}
+}
+
+func newWebSocketTracker() *webSocketTracker {
+	return &webSocketTracker{conns: map[*websocket.Conn]struct{}{}}
+}
+
+// add registers conn. When the server is already shutting down,
+// the client is told to go away right away.
+func (t *webSocketTracker) add(conn *websocket.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closing {
+		goAway(conn, t.deadline)
+		return
+	}
+	t.conns[conn] = struct{}{}
+}
+
+// reset clears the state of a previous shutdown, so a server can be started again.
+func (t *webSocketTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closing = false
+	t.deadline = time.Time{}
+}
+
+// begin registers a WebSocket handler about to run. It reports false once the server
+// is shutting down, so that no handler starts after wait began to drain them.
+func (t *webSocketTracker) begin() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closing {
+		return false
+	}
+	t.handlers++
+	return true
+}
+
+// end unregisters a handler registered with begin.
+func (t *webSocketTracker) end() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.handlers--
+	if t.handlers == 0 && t.drained != nil {
+		close(t.drained)
+		t.drained = nil
+	}
+}
+
+func (t *webSocketTracker) remove(conn *websocket.Conn) {
+	t.mu.Lock()
+	delete(t.conns, conn)
+	t.mu.Unlock()
+}
+
+// goAway sends a close frame to every tracked connection.
+func (t *webSocketTracker) goAway(deadline time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closing = true
+	t.deadline = deadline
+	for conn := range t.conns {
+		goAway(conn, deadline)
+	}
+}
+
+// closeAll closes every tracked connection without a close handshake.
+func (t *webSocketTracker) closeAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for conn := range t.conns {
+		conn.Close()
+	}
+}
+
+// wait blocks until every WebSocket handler has returned or ctx is done.
+func (t *webSocketTracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if t.handlers == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.drained == nil {
+		t.drained = make(chan struct{})
+	}
+	drained := t.drained
+	t.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func goAway(conn *websocket.Conn, deadline time.Time) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	conn.WriteControl(websocket.CloseMessage, msg, deadline)
+}
+
+// UpgradeWebSocket upgrades the request to a WebSocket connection with upgrader.
+// Connections upgraded this way receive a close frame during a graceful shutdown
+// for as long as the WebSocketHandler that upgraded them is running.
+func (resp *HTTPResponse) UpgradeWebSocket(request *HTTPRequest, upgrader *websocket.Upgrader, header http.Header) (*websocket.Conn, error) {
+	conn, err := upgrader.Upgrade(resp.recorder(), request.HTTP, header)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.upgraded = append(resp.upgraded, conn)
+	if resp.webSockets != nil {
+		resp.webSockets.add(conn)
+	}
+	if resp.metrics != nil {
+		resp.metrics.webSocketOpened()
+	}
+	return conn, nil
+}
+
+// releaseWebSockets stops tracking the connections upgraded through this response.
+func (resp *HTTPResponse) releaseWebSockets() {
+	for _, conn := range resp.upgraded {
+		if resp.webSockets != nil {
+			resp.webSockets.remove(conn)
+		}
+		if resp.metrics != nil {
+			resp.metrics.webSocketClosed()
+		}
+	}
+	resp.upgraded = nil
+}
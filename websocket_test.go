@@ -0,0 +1,127 @@
+package streamgo
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func dialWebSocket(t *testing.T, addr string) *websocket.Conn {
+	t.Helper()
+
+	var (
+		conn *websocket.Conn
+		err  error
+	)
+	for i := 0; i < 50; i++ {
+		if conn, _, err = websocket.DefaultDialer.Dial("ws://"+addr+"/ws", nil); err == nil {
+			return conn
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(err)
+	return nil
+}
+
+func TestWebSocketGoAwayOnShutdownAndRestart(t *testing.T) {
+	s := newTestServer(t, nil, []Path[string]{{Name: "/ws", WebSocket: WS{Upgrader: &websocket.Upgrader{}}}})
+	s.ShutdownTimeout = time.Second
+	s.WebSocketHandler = func(request *HTTPRequest, response *HTTPResponse, payload string, upgrader *websocket.Upgrader) {
+		conn, err := response.UpgradeWebSocket(request, upgrader, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
+	// The first run ends with a graceful shutdown, which tells the client to go away.
+	addrs, stop := listenLoopback(t, s, ListenerConfig{Name: "ws"})
+	addr := addrs[0]
+	conn := dialWebSocket(t, addr)
+	go stop()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("first run: ReadMessage = %v, want a going away close", err)
+	}
+	conn.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	// After a restart, new connections must not be sent away right away.
+	addrs, stop = listenLoopback(t, s, ListenerConfig{Name: "ws"})
+	addr = addrs[0]
+	defer stop()
+	conn = dialWebSocket(t, addr)
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err = conn.ReadMessage()
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("second run: ReadMessage = %v, want a timeout", err)
+	}
+}
+
+func TestWebSocketTrackerDrain(t *testing.T) {
+	tracker := newWebSocketTracker()
+	if !tracker.begin() {
+		t.Fatal("begin refused a handler before shutdown")
+	}
+
+	tracker.goAway(time.Now().Add(time.Second))
+	if tracker.begin() {
+		t.Fatal("begin accepted a handler after shutdown began")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- tracker.wait(context.Background()) }()
+	select {
+	case err := <-done:
+		t.Fatalf("wait returned %v with a handler still running", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	tracker.end()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("wait = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wait did not return after the last handler ended")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := tracker.wait(ctx); err != nil {
+		t.Errorf("wait without handlers = %v, want nil", err)
+	}
+
+	tracker.reset()
+	if !tracker.begin() {
+		t.Error("begin refused a handler after reset")
+	}
+}
+
+func TestWebSocketUpgradeRefusedDuringShutdown(t *testing.T) {
+	called := false
+	s := newTestServer(t, nil, []Path[string]{{Name: "/ws", WebSocket: WS{Upgrader: &websocket.Upgrader{}}}})
+	s.WebSocketHandler = func(request *HTTPRequest, response *HTTPResponse, payload string, upgrader *websocket.Upgrader) {
+		called = true
+	}
+	s.webSockets.goAway(time.Now())
+
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	if w := do(s, r); w.Code != 503 || called {
+		t.Errorf("upgrade during shutdown = %d, handler called %v, want 503 without the handler", w.Code, called)
+	}
+}